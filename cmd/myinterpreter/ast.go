@@ -1,5 +1,7 @@
 package main
 
+import "math/big"
+
 type AST interface {
 	accept(visitor AstVisitor)
 }
@@ -45,6 +47,21 @@ func (v *VarDecl) accept(visitor AstVisitor) {
 	visitor.visitVarDecl(v)
 }
 
+// DestructuringDecl declares all variables of a (possibly nested) tuple
+// pattern like var (x, (y, z)) = value;
+type DestructuringDecl struct {
+	pattern    *TupleExpr
+	expression AST
+}
+
+func NewDestructuringDecl(pattern *TupleExpr, expression AST) *DestructuringDecl {
+	return &DestructuringDecl{pattern: pattern, expression: expression}
+}
+
+func (d *DestructuringDecl) accept(visitor AstVisitor) {
+	visitor.visitDestructuringDecl(d)
+}
+
 type PrintStatement struct {
 	expression AST
 }
@@ -69,6 +86,54 @@ func (r *ReturnStatement) accept(visitor AstVisitor) {
 	visitor.visitReturnStmt(r)
 }
 
+type YieldStatement struct {
+	expression AST
+}
+
+func NewYieldStatement(expression AST) *YieldStatement {
+	return &YieldStatement{expression: expression}
+}
+
+func (y *YieldStatement) accept(visitor AstVisitor) {
+	visitor.visitYieldStmt(y)
+}
+
+type DeferStatement struct {
+	call Expr // a call or a path expression ending in a method call
+}
+
+func NewDeferStatement(call Expr) *DeferStatement {
+	return &DeferStatement{call}
+}
+
+func (d *DeferStatement) accept(visitor AstVisitor) {
+	visitor.visitDeferStmt(d)
+}
+
+// SelectStatement waits until one of its cases can communicate on its channel
+// and runs the body of that case. Without a default case it blocks.
+type SelectStatement struct {
+	cases       []SelectCase
+	defaultCase *Block
+}
+
+func NewSelectStatement(cases []SelectCase, defaultCase *Block) *SelectStatement {
+	return &SelectStatement{cases, defaultCase}
+}
+
+func (s *SelectStatement) accept(visitor AstVisitor) {
+	visitor.visitSelectStmt(s)
+}
+
+// SelectCase is either a send (channel.send(value)) or a receive
+// (channel.recv()), whose value may be bound to a variable
+type SelectCase struct {
+	channel  Expr
+	value    Expr // value to send, nil for receiving
+	variable string
+	body     Block
+}
+
 type ExpressionStatement struct {
 	expression AST
 }
@@ -98,10 +163,11 @@ func (i *IfStatement) accept(visitor AstVisitor) {
 type WhileStatement struct {
 	condition Expr
 	statement Statement
+	label     string
 }
 
 func NewWhileStatement(condition Expr, statement Statement) *WhileStatement {
-	return &WhileStatement{condition, statement}
+	return &WhileStatement{condition: condition, statement: statement}
 }
 
 func (w *WhileStatement) accept(visitor AstVisitor) {
@@ -113,20 +179,89 @@ type ForStatement struct {
 	condition   Expr
 	increment   Expr
 	statement   Statement
+	label       string
 }
 
 func NewForStatement(initializer Statement, condition Expr, increment, statement Statement) *ForStatement {
-	return &ForStatement{initializer, condition, increment, statement}
+	return &ForStatement{
+		initializer: initializer,
+		condition:   condition,
+		increment:   increment,
+		statement:   statement,
+	}
 }
 
 func (f *ForStatement) accept(visitor AstVisitor) {
 	visitor.visitForStmt(f)
 }
 
+type ForInStatement struct {
+	variable  string
+	iterable  Expr
+	statement Statement
+	label     string
+}
+
+func NewForInStatement(variable string, iterable Expr, statement Statement) *ForInStatement {
+	return &ForInStatement{
+		variable:  variable,
+		iterable:  iterable,
+		statement: statement,
+	}
+}
+
+func (f *ForInStatement) accept(visitor AstVisitor) {
+	visitor.visitForInStmt(f)
+}
+
+// LoopStatement is implemented by all statements that can be labeled
+// and targeted by break or continue
+type LoopStatement interface {
+	Statement
+	setLabel(label string)
+}
+
+func (w *WhileStatement) setLabel(label string) {
+	w.label = label
+}
+
+func (f *ForStatement) setLabel(label string) {
+	f.label = label
+}
+
+func (f *ForInStatement) setLabel(label string) {
+	f.label = label
+}
+
+type BreakStatement struct {
+	label string // empty for the innermost loop
+}
+
+func NewBreakStatement(label string) *BreakStatement {
+	return &BreakStatement{label}
+}
+
+func (b *BreakStatement) accept(visitor AstVisitor) {
+	visitor.visitBreakStmt(b)
+}
+
+type ContinueStatement struct {
+	label string // empty for the innermost loop
+}
+
+func NewContinueStatement(label string) *ContinueStatement {
+	return &ContinueStatement{label}
+}
+
+func (c *ContinueStatement) accept(visitor AstVisitor) {
+	visitor.visitContinueStmt(c)
+}
+
 type ClassDef struct {
-	name       string
-	superClass string
-	functions  []FunctionDef
+	name         string
+	superClass   string
+	functions    []FunctionDef // methods and static methods
+	staticFields []VarDecl
 }
 
 func NewClassDef(name, superClass string) *ClassDef {
@@ -140,25 +275,66 @@ func (c *ClassDef) addFunction(function FunctionDef) {
 	c.functions = append(c.functions, function)
 }
 
+func (c *ClassDef) addStaticField(field VarDecl) {
+	c.staticFields = append(c.staticFields, field)
+}
+
 func (c *ClassDef) accept(visitor AstVisitor) {
 	visitor.visitClassDef(c)
 }
 
+// Parameter of a function, defaultValue is nil for required parameters. A rest
+// parameter (...name) collects all remaining positional arguments in a list.
+type Parameter struct {
+	name         string
+	defaultValue Expr
+	isRest       bool
+}
+
 type FunctionDef struct {
-	name       string
-	parameters []string
-	body       Block
-	class      *ClassDef
+	name        string
+	parameters  []Parameter
+	body        Block
+	class       *ClassDef
+	isGenerator bool
+	isAsync     bool
+	isStatic    bool   // static methods belong to the class instead of its instances
+	isGetter    bool   // get name() { ... } is called when the property is read
+	isSetter    bool   // set name(value) { ... } is called when the property is assigned
+	decorators  []Expr // in source order, applied from the last to the first
+}
+
+func NewFunctionDef(class *ClassDef, name string, parameters []Parameter, body Block) *FunctionDef {
+	return &FunctionDef{
+		name:       name,
+		parameters: parameters,
+		body:       body,
+		class:      class,
+	}
 }
 
-func NewFunctionDef(class *ClassDef, name string, parameters []string, body Block) *FunctionDef {
-	return &FunctionDef{name, parameters, body, class}
+func (f *FunctionDef) isAccessor() bool {
+	return f.isGetter || f.isSetter
 }
 
 func (f *FunctionDef) accept(visitor AstVisitor) {
 	visitor.visitFunctionDef(f)
 }
 
+// FunctionExpr is an anonymous function like fun (a) { ... } or (a) => a * 2.
+// The name of its function definition is empty.
+type FunctionExpr struct {
+	function *FunctionDef
+}
+
+func NewFunctionExpr(function *FunctionDef) *FunctionExpr {
+	return &FunctionExpr{function}
+}
+
+func (f *FunctionExpr) accept(visitor AstVisitor) {
+	visitor.visitFunctionExpr(f)
+}
+
 type Expr interface {
 	AST
 }
@@ -175,6 +351,18 @@ func (num *NumberExpr) accept(visitor AstVisitor) {
 	visitor.visitNumberExpr(num)
 }
 
+type IntegerExpr struct {
+	Value *big.Int
+}
+
+func NewIntegerExpr(value *big.Int) *IntegerExpr {
+	return &IntegerExpr{value}
+}
+
+func (integer *IntegerExpr) accept(visitor AstVisitor) {
+	visitor.visitIntegerExpr(integer)
+}
+
 type BooleanExpr struct {
 	Value bool
 }
@@ -209,6 +397,19 @@ func (string *StringExpr) accept(visitor AstVisitor) {
 	visitor.visitStringExpr(string)
 }
 
+// InterpolationExpr concatenates the string representations of its parts
+type InterpolationExpr struct {
+	parts []Expr
+}
+
+func NewInterpolationExpr(parts []Expr) *InterpolationExpr {
+	return &InterpolationExpr{parts}
+}
+
+func (interpolation *InterpolationExpr) accept(visitor AstVisitor) {
+	visitor.visitInterpolationExpr(interpolation)
+}
+
 type IdentifierExpr struct {
 	name     string
 	defLevel int // defined <defLevel> levels above the current scope
@@ -279,14 +480,16 @@ func (assignment *Assignment) accept(visitor AstVisitor) {
 }
 
 type Call struct {
-	callee Expr
-	args   []Expr
+	callee   Expr
+	args     []Expr
+	argNames []string // names of named arguments, empty for positional arguments
 }
 
-func NewCall(callee Expr, args []Expr) *Call {
+func NewCall(callee Expr, args []Expr, argNames []string) *Call {
 	return &Call{
-		callee: callee,
-		args:   args,
+		callee:   callee,
+		args:     args,
+		argNames: argNames,
 	}
 }
 
@@ -294,26 +497,161 @@ func (call *Call) accept(visitor AstVisitor) {
 	visitor.visitCall(call)
 }
 
+type ListExpr struct {
+	elements []Expr
+}
+
+func NewListExpr(elements []Expr) *ListExpr {
+	return &ListExpr{elements}
+}
+
+func (list *ListExpr) accept(visitor AstVisitor) {
+	visitor.visitListExpr(list)
+}
+
+type TupleExpr struct {
+	elements []Expr
+}
+
+func NewTupleExpr(elements []Expr) *TupleExpr {
+	return &TupleExpr{elements}
+}
+
+func (tuple *TupleExpr) accept(visitor AstVisitor) {
+	visitor.visitTupleExpr(tuple)
+}
+
+// names returns the names of all variables of a destructuring pattern
+func (tuple *TupleExpr) names() []string {
+	var names []string
+	for _, element := range tuple.elements {
+		switch e := element.(type) {
+		case *IdentifierExpr:
+			names = append(names, e.name)
+		case *TupleExpr:
+			names = append(names, e.names()...)
+		}
+	}
+	return names
+}
+
+// SpreadExpr passes the elements of an iterable as separate arguments: f(...args)
+type SpreadExpr struct {
+	inner Expr
+}
+
+func NewSpreadExpr(inner Expr) *SpreadExpr {
+	return &SpreadExpr{inner}
+}
+
+func (spread *SpreadExpr) accept(visitor AstVisitor) {
+	visitor.visitSpreadExpr(spread)
+}
+
+type MapExpr struct {
+	keys   []Expr
+	values []Expr
+}
+
+func NewMapExpr(keys, values []Expr) *MapExpr {
+	return &MapExpr{keys, values}
+}
+
+func (mapExpr *MapExpr) accept(visitor AstVisitor) {
+	visitor.visitMapExpr(mapExpr)
+}
+
+type IndexExpr struct {
+	object Expr
+	index  Expr
+}
+
+func NewIndexExpr(object, index Expr) *IndexExpr {
+	return &IndexExpr{object, index}
+}
+
+func (indexExpr *IndexExpr) accept(visitor AstVisitor) {
+	visitor.visitIndexExpr(indexExpr)
+}
+
+type SliceExpr struct {
+	object Expr
+	start  Expr // nil if omitted
+	end    Expr // nil if omitted
+}
+
+func NewSliceExpr(object, start, end Expr) *SliceExpr {
+	return &SliceExpr{object, start, end}
+}
+
+func (sliceExpr *SliceExpr) accept(visitor AstVisitor) {
+	visitor.visitSliceExpr(sliceExpr)
+}
+
+// SpawnExpr runs a function or method call on a new task
+type SpawnExpr struct {
+	call Expr
+}
+
+func NewSpawnExpr(call Expr) *SpawnExpr {
+	return &SpawnExpr{call}
+}
+
+func (s *SpawnExpr) accept(visitor AstVisitor) {
+	visitor.visitSpawnExpr(s)
+}
+
+// AwaitExpr suspends an async function until a promise is settled
+type AwaitExpr struct {
+	value Expr
+}
+
+func NewAwaitExpr(value Expr) *AwaitExpr {
+	return &AwaitExpr{value}
+}
+
+func (a *AwaitExpr) accept(visitor AstVisitor) {
+	visitor.visitAwaitExpr(a)
+}
+
 type AstVisitor interface {
 	visitProgram(program *Program)
 	visitBlock(block *Block)
 	visitVarDecl(varDecl *VarDecl)
+	visitDestructuringDecl(decl *DestructuringDecl)
 	visitPrint(printStmt *PrintStatement)
 	visitReturnStmt(returnStmt *ReturnStatement)
+	visitYieldStmt(yieldStmt *YieldStatement)
+	visitDeferStmt(deferStmt *DeferStatement)
+	visitSelectStmt(selectStmt *SelectStatement)
 	visitExprStmt(exprStmt *ExpressionStatement)
 	visitIfStmt(ifStmt *IfStatement)
 	visitWhileStmt(whileStmt *WhileStatement)
 	visitForStmt(f *ForStatement)
+	visitForInStmt(f *ForInStatement)
+	visitBreakStmt(b *BreakStatement)
+	visitContinueStmt(c *ContinueStatement)
 	visitClassDef(c *ClassDef)
 	visitFunctionDef(f *FunctionDef)
+	visitFunctionExpr(f *FunctionExpr)
 	visitNumberExpr(numberExpr *NumberExpr)
+	visitIntegerExpr(integerExpr *IntegerExpr)
 	visitBooleanExpr(booleanExpr *BooleanExpr)
 	visitNilExpr()
 	visitStringExpr(stringExpr *StringExpr)
+	visitInterpolationExpr(interpolation *InterpolationExpr)
 	visitIdentifierExpr(identifierExpr *IdentifierExpr)
 	visitGroupExpr(groupExpr *GroupExpr)
 	visitUnaryExpr(unaryExpr *UnaryExpr)
 	visitBinaryExpr(expr *BinaryExpr)
 	visitAssignment(assignment *Assignment)
 	visitCall(call *Call)
+	visitSpreadExpr(spread *SpreadExpr)
+	visitSpawnExpr(spawn *SpawnExpr)
+	visitAwaitExpr(awaitExpr *AwaitExpr)
+	visitListExpr(list *ListExpr)
+	visitTupleExpr(tuple *TupleExpr)
+	visitMapExpr(mapExpr *MapExpr)
+	visitIndexExpr(indexExpr *IndexExpr)
+	visitSliceExpr(sliceExpr *SliceExpr)
 }
@@ -18,10 +18,18 @@ func (ap *AstPrinter) visitBlock(*Block) {}
 
 func (ap *AstPrinter) visitVarDecl(*VarDecl) {}
 
+func (ap *AstPrinter) visitDestructuringDecl(*DestructuringDecl) {}
+
 func (ap *AstPrinter) visitPrint(*PrintStatement) {}
 
 func (ap *AstPrinter) visitReturnStmt(*ReturnStatement) {}
 
+func (ap *AstPrinter) visitYieldStmt(*YieldStatement) {}
+
+func (ap *AstPrinter) visitDeferStmt(*DeferStatement) {}
+
+func (ap *AstPrinter) visitSelectStmt(*SelectStatement) {}
+
 func (ap *AstPrinter) visitExprStmt(*ExpressionStatement) {}
 
 func (ap *AstPrinter) visitIfStmt(*IfStatement) {}
@@ -30,16 +38,34 @@ func (ap *AstPrinter) visitWhileStmt(*WhileStatement) {}
 
 func (ap *AstPrinter) visitForStmt(*ForStatement) {}
 
+func (ap *AstPrinter) visitForInStmt(*ForInStatement) {}
+
+func (ap *AstPrinter) visitBreakStmt(*BreakStatement) {}
+
+func (ap *AstPrinter) visitContinueStmt(*ContinueStatement) {}
+
 func (ap *AstPrinter) visitClassDef(*ClassDef) {}
 
 func (ap *AstPrinter) visitFunctionDef(*FunctionDef) {}
 
-func (ap *AstPrinter) visitNumberExpr(num *NumberExpr) {
-	numStr := strings.TrimRight(fmt.Sprintf("%f", num.Value), "0")
-	if numStr[len(numStr)-1] == uint8('.') {
-		numStr = numStr + "0"
+func (ap *AstPrinter) visitFunctionExpr(f *FunctionExpr) {
+	var names []string
+	for _, param := range f.function.parameters {
+		if param.isRest {
+			names = append(names, "..."+param.name)
+		} else {
+			names = append(names, param.name)
+		}
 	}
-	fmt.Print(numStr)
+	fmt.Printf("(fun (%s))", strings.Join(names, " "))
+}
+
+func (ap *AstPrinter) visitNumberExpr(num *NumberExpr) {
+	fmt.Print(floatValueToStr(num.Value))
+}
+
+func (ap *AstPrinter) visitIntegerExpr(integer *IntegerExpr) {
+	fmt.Printf("%s.0", integer.Value)
 }
 
 func (ap *AstPrinter) visitBooleanExpr(be *BooleanExpr) {
@@ -54,6 +80,15 @@ func (ap *AstPrinter) visitStringExpr(str *StringExpr) {
 	fmt.Printf("%s", str.Value)
 }
 
+func (ap *AstPrinter) visitInterpolationExpr(interpolation *InterpolationExpr) {
+	fmt.Printf("(interpolate")
+	for _, part := range interpolation.parts {
+		fmt.Printf(" ")
+		part.accept(ap)
+	}
+	fmt.Printf(")")
+}
+
 func (ap *AstPrinter) visitIdentifierExpr(id *IdentifierExpr) {
 	fmt.Printf("id(%s)", id.name)
 }
@@ -89,3 +124,72 @@ func (ap *AstPrinter) visitAssignment(assignment *Assignment) {
 func (ap *AstPrinter) visitCall(call *Call) {
 	fmt.Printf("(call %s", call.callee)
 }
+
+func (ap *AstPrinter) visitSpreadExpr(spread *SpreadExpr) {
+	fmt.Printf("(... ")
+	spread.inner.accept(ap)
+	fmt.Printf(")")
+}
+
+func (ap *AstPrinter) visitListExpr(list *ListExpr) {
+	fmt.Printf("(list")
+	for _, element := range list.elements {
+		fmt.Printf(" ")
+		element.accept(ap)
+	}
+	fmt.Printf(")")
+}
+
+func (ap *AstPrinter) visitTupleExpr(tuple *TupleExpr) {
+	fmt.Printf("(tuple")
+	for _, element := range tuple.elements {
+		fmt.Printf(" ")
+		element.accept(ap)
+	}
+	fmt.Printf(")")
+}
+
+func (ap *AstPrinter) visitMapExpr(mapExpr *MapExpr) {
+	fmt.Printf("(map")
+	for i, key := range mapExpr.keys {
+		fmt.Printf(" ")
+		key.accept(ap)
+		fmt.Printf(" ")
+		mapExpr.values[i].accept(ap)
+	}
+	fmt.Printf(")")
+}
+
+func (ap *AstPrinter) visitIndexExpr(indexExpr *IndexExpr) {
+	fmt.Printf("(index ")
+	indexExpr.object.accept(ap)
+	fmt.Printf(" ")
+	indexExpr.index.accept(ap)
+	fmt.Printf(")")
+}
+
+func (ap *AstPrinter) visitSliceExpr(sliceExpr *SliceExpr) {
+	fmt.Printf("(slice ")
+	sliceExpr.object.accept(ap)
+	for _, bound := range []Expr{sliceExpr.start, sliceExpr.end} {
+		fmt.Printf(" ")
+		if bound != nil {
+			bound.accept(ap)
+		} else {
+			fmt.Printf("nil")
+		}
+	}
+	fmt.Printf(")")
+}
+
+func (ap *AstPrinter) visitSpawnExpr(spawn *SpawnExpr) {
+	fmt.Print("(spawn ")
+	spawn.call.accept(ap)
+	fmt.Print(")")
+}
+
+func (ap *AstPrinter) visitAwaitExpr(awaitExpr *AwaitExpr) {
+	fmt.Print("(await ")
+	awaitExpr.value.accept(ap)
+	fmt.Print(")")
+}
@@ -0,0 +1,512 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// eventLoop runs asynchronous tasks and timers on a single goroutine. It is
+// owned by the interpreter of a program, which keeps running the loop after
+// the program until nothing is pending any more.
+//
+// Timers are ordered by a virtual clock: the due time of a timer is relative
+// to the due time of the timer currently running, so the order of events
+// does not depend on how long the callbacks take.
+type eventLoop struct {
+	mu        sync.Mutex // tasks spawned on other goroutines may add work
+	queue     []func() error
+	timers    timerQueue
+	active    map[int]*timer
+	nextID    int
+	seq       int
+	now       time.Duration
+	start     time.Time
+	unhandled []*PromiseValue // rejected promises without handler
+}
+
+func newEventLoop() *eventLoop {
+	return &eventLoop{active: make(map[int]*timer), start: time.Now()}
+}
+
+func (loop *eventLoop) enqueue(job func() error) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	loop.queue = append(loop.queue, job)
+}
+
+// run processes queued jobs and timers until none are left. An error of a
+// callback or a rejection nobody handles stops the loop.
+func (loop *eventLoop) run() error {
+	for {
+		job, t := loop.nextJob()
+		if job == nil && t == nil {
+			break
+		}
+		if t != nil {
+			time.Sleep(time.Until(loop.start.Add(t.due)))
+			job = t.fire
+		}
+		err := job()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, promise := range loop.unhandled {
+		if !promise.handled {
+			return promise.err
+		}
+	}
+	return nil
+}
+
+// nextJob returns the next queued job, or if there is none, the next timer
+func (loop *eventLoop) nextJob() (func() error, *timer) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	if len(loop.queue) > 0 {
+		job := loop.queue[0]
+		loop.queue = loop.queue[1:]
+		return job, nil
+	}
+	if len(loop.timers) == 0 {
+		return nil, nil
+	}
+	t := heap.Pop(&loop.timers).(*timer)
+	loop.now = t.due
+	if t.interval > 0 {
+		loop.seq++
+		t.due, t.seq = t.due+t.interval, loop.seq
+		heap.Push(&loop.timers, t)
+	} else {
+		delete(loop.active, t.id)
+	}
+	return nil, t
+}
+
+func (loop *eventLoop) addTimer(fn callable, args []Value, delay time.Duration, repeat bool) int {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	loop.nextID++
+	loop.seq++
+	t := &timer{id: loop.nextID, seq: loop.seq, due: loop.now + delay, fn: fn, args: args}
+	if repeat {
+		t.interval = max(delay, time.Millisecond)
+	}
+	heap.Push(&loop.timers, t)
+	loop.active[t.id] = t
+	return t.id
+}
+
+func (loop *eventLoop) clearTimer(id int) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	t, ok := loop.active[id]
+	if !ok {
+		return
+	}
+	delete(loop.active, id)
+	heap.Remove(&loop.timers, t.index)
+}
+
+func (loop *eventLoop) addUnhandled(promise *PromiseValue) {
+	loop.mu.Lock()
+	defer loop.mu.Unlock()
+	loop.unhandled = append(loop.unhandled, promise)
+}
+
+type timer struct {
+	id       int
+	seq      int // tells apart timers with the same due time
+	due      time.Duration
+	interval time.Duration // zero for timeouts
+	fn       callable
+	args     []Value
+	index    int // position in the timer queue
+}
+
+func (t *timer) fire() error {
+	_, err := t.fn.call(t.args)
+	return err
+}
+
+// timerQueue is a heap of timers ordered by due time and creation
+type timerQueue []*timer
+
+func (q timerQueue) Len() int {
+	return len(q)
+}
+
+func (q timerQueue) Less(i, j int) bool {
+	if q[i].due != q[j].due {
+		return q[i].due < q[j].due
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x any) {
+	t := x.(*timer)
+	t.index = len(*q)
+	*q = append(*q, t)
+}
+
+func (q *timerQueue) Pop() any {
+	old := *q
+	t := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return t
+}
+
+type promiseState int
+
+const (
+	promisePending promiseState = iota
+	promiseFulfilled
+	promiseRejected
+)
+
+// PromiseValue is the eventual result of an asynchronous operation. Callbacks
+// waiting for it are run by the event loop once it is settled.
+type PromiseValue struct {
+	loop      *eventLoop
+	mu        sync.Mutex
+	state     promiseState
+	value     Value
+	err       error
+	callbacks []func() error
+	handled   bool // a callback has been registered, so rejections are not lost
+}
+
+func NewPromiseValue(loop *eventLoop) *PromiseValue {
+	return &PromiseValue{loop: loop}
+}
+
+func (p *PromiseValue) getType() ValueType {
+	return VtPromise
+}
+
+func (p *PromiseValue) isEqualTo(value Value) bool {
+	other, ok := value.(*PromiseValue)
+	return ok && p == other
+}
+
+func (p *PromiseValue) isTruthy() bool {
+	return true
+}
+
+func (p *PromiseValue) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch p.state {
+	case promiseFulfilled:
+		return fmt.Sprintf("<promise %s>", reprValue(p.value))
+	case promiseRejected:
+		return "<promise rejected>"
+	default:
+		return "<promise pending>"
+	}
+}
+
+// resolve fulfills the promise with value. If value is a promise itself, the
+// promise follows it.
+func (p *PromiseValue) resolve(value Value) {
+	other, isPromise := value.(*PromiseValue)
+	if isPromise {
+		other.onSettled(func() error {
+			p.settle(other.result())
+			return nil
+		})
+		return
+	}
+	p.settle(value, nil)
+}
+
+func (p *PromiseValue) reject(err error) {
+	p.settle(nil, err)
+}
+
+func (p *PromiseValue) settle(value Value, err error) {
+	p.mu.Lock()
+	if p.state != promisePending {
+		p.mu.Unlock()
+		return
+	}
+	if err != nil {
+		p.state, p.err = promiseRejected, err
+	} else {
+		p.state, p.value = promiseFulfilled, value
+	}
+	callbacks := p.callbacks
+	p.callbacks = nil
+	handled := p.handled
+	p.mu.Unlock()
+
+	if err != nil && !handled {
+		p.loop.addUnhandled(p)
+	}
+	for _, callback := range callbacks {
+		p.loop.enqueue(callback)
+	}
+}
+
+func (p *PromiseValue) isSettled() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.state != promisePending
+}
+
+func (p *PromiseValue) result() (Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.value, p.err
+}
+
+// onSettled registers a callback which is run by the event loop once the
+// promise is settled
+func (p *PromiseValue) onSettled(callback func() error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.handled = true
+	if p.state == promisePending {
+		p.callbacks = append(p.callbacks, callback)
+	} else {
+		p.loop.enqueue(callback)
+	}
+}
+
+func (p *PromiseValue) getMember(name string) (Value, error) {
+	switch name {
+	case "then":
+		return NewBuiltinFuncValue("then", 1, 1, func(args []Value) (Value, error) {
+			return p.then(args[0])
+		}), nil
+	default:
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+}
+
+// then calls fn with the value of the promise once it is fulfilled. The
+// returned promise is resolved with the result of fn. Rejections are passed
+// on without calling fn.
+func (p *PromiseValue) then(value Value) (Value, error) {
+	fn, ok := value.(callable)
+	if !ok {
+		return nil, fmt.Errorf("then() expects a callable but got %s", value)
+	}
+	ret := NewPromiseValue(p.loop)
+	p.onSettled(func() error {
+		value, err := p.result()
+		if err == nil {
+			value, err = fn.call([]Value{value})
+		}
+		if err != nil {
+			ret.reject(err)
+		} else {
+			ret.resolve(value)
+		}
+		return nil
+	})
+	return ret, nil
+}
+
+// asyncTask runs the body of an async function. Like generators the body runs
+// on its own goroutine, but control is handed over so that only one of the
+// body and the event loop is running at any time.
+type asyncTask struct {
+	lambda  *LambdaValue
+	callEnv *Environment
+	promise *PromiseValue
+	resume  chan struct{}
+	pause   chan struct{}
+}
+
+// startAsync calls an async function. The body runs until the first await of
+// a pending promise, then the promise for the result of the call is returned.
+func startAsync(lambda *LambdaValue, callEnv *Environment, depth int) *PromiseValue {
+	task := &asyncTask{
+		lambda:  lambda,
+		callEnv: callEnv,
+		promise: NewPromiseValue(callEnv.eventLoop()),
+		resume:  make(chan struct{}),
+		pause:   make(chan struct{}),
+	}
+	go task.run(depth)
+	<-task.pause
+	return task.promise
+}
+
+func (task *asyncTask) run(depth int) {
+	interpreter := NewInterpreter(task.callEnv)
+	interpreter.callDepth = depth
+	interpreter.lambdaEvalActive = true
+	interpreter.task = task
+
+	interpreter.visitBlock(&task.lambda.body)
+	interpreter.runDeferred()
+
+	if interpreter.lastError != nil {
+		task.promise.reject(interpreter.lastError)
+	} else {
+		task.promise.resolve(interpreter.lastResult)
+	}
+	task.pause <- struct{}{}
+}
+
+// await suspends the task until the promise is settled. Other values are
+// returned as they are.
+func (task *asyncTask) await(value Value) (Value, error) {
+	promise, isPromise := value.(*PromiseValue)
+	if !isPromise {
+		return value, nil
+	}
+	if !promise.isSettled() {
+		promise.onSettled(func() error {
+			task.resume <- struct{}{}
+			<-task.pause
+			return nil
+		})
+		task.pause <- struct{}{}
+		<-task.resume
+	}
+	promise.mu.Lock()
+	promise.handled = true
+	promise.mu.Unlock()
+	return promise.result()
+}
+
+// initLoopBuiltins defines the builtins for timers and promises, which are
+// bound to the event loop of the program
+func initLoopBuiltins(values map[string]Value, loop *eventLoop) {
+	values["setTimeout"] = NewBuiltinFuncValue("setTimeout", 2, variadic, func(args []Value) (Value, error) {
+		return loop.setTimer("setTimeout", args, false)
+	})
+	values["setInterval"] = NewBuiltinFuncValue("setInterval", 2, variadic, func(args []Value) (Value, error) {
+		return loop.setTimer("setInterval", args, true)
+	})
+	clear := func(args []Value) (Value, error) {
+		id, err := valueToInt(args[0])
+		if err != nil {
+			return nil, err
+		}
+		loop.clearTimer(id)
+		return NewNilValue(), nil
+	}
+	values["clearTimeout"] = NewBuiltinFuncValue("clearTimeout", 1, 1, clear)
+	values["clearInterval"] = NewBuiltinFuncValue("clearInterval", 1, 1, clear)
+	values["sleep"] = NewBuiltinFuncValue("sleep", 1, 1, func(args []Value) (Value, error) {
+		delay, err := toDuration("sleep", args[0])
+		if err != nil {
+			return nil, err
+		}
+		promise := NewPromiseValue(loop)
+		resolve := NewBuiltinFuncValue("resolve", 0, 0, func([]Value) (Value, error) {
+			promise.resolve(NewNilValue())
+			return NewNilValue(), nil
+		})
+		loop.addTimer(resolve, nil, delay, false)
+		return promise, nil
+	})
+	values["all"] = NewBuiltinFuncValueAt("all", 1, 1, func(depth int, args []Value) (Value, error) {
+		return loop.all(depth, args[0])
+	})
+	values["race"] = NewBuiltinFuncValueAt("race", 1, 1, func(depth int, args []Value) (Value, error) {
+		return loop.race(depth, args[0])
+	})
+}
+
+func (loop *eventLoop) setTimer(name string, args []Value, repeat bool) (Value, error) {
+	fn, ok := args[0].(callable)
+	if !ok {
+		return nil, fmt.Errorf("%s() expects a callable but got %s", name, args[0])
+	}
+	delay, err := toDuration(name, args[1])
+	if err != nil {
+		return nil, err
+	}
+	return NewIntValue(int64(loop.addTimer(fn, args[2:], delay, repeat))), nil
+}
+
+func toDuration(name string, value Value) (time.Duration, error) {
+	if !isNumber(value) || toFloat(value) < 0 {
+		return 0, fmt.Errorf("%s() expects a non-negative number of milliseconds but got %s", name, value)
+	}
+	return time.Duration(toFloat(value) * float64(time.Millisecond)), nil
+}
+
+// promises converts the elements of an iterable to promises. Other values
+// count as fulfilled promises.
+func (loop *eventLoop) promises(depth int, value Value) ([]*PromiseValue, error) {
+	elements, err := collect(depth, value)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*PromiseValue, len(elements))
+	for i, element := range elements {
+		promise, isPromise := element.(*PromiseValue)
+		if !isPromise {
+			promise = NewPromiseValue(loop)
+			promise.resolve(element)
+		}
+		ret[i] = promise
+	}
+	return ret, nil
+}
+
+// all returns a promise for the list of the values of all promises. It is
+// rejected as soon as one of the promises is rejected.
+func (loop *eventLoop) all(depth int, value Value) (Value, error) {
+	promises, err := loop.promises(depth, value)
+	if err != nil {
+		return nil, err
+	}
+	ret := NewPromiseValue(loop)
+	values := make([]Value, len(promises))
+	remaining := len(promises)
+	if remaining == 0 {
+		ret.resolve(NewListValue(values))
+	}
+	for i, promise := range promises {
+		promise.onSettled(func() error {
+			value, err := promise.result()
+			if err != nil {
+				ret.reject(err)
+				return nil
+			}
+			values[i] = value
+			remaining--
+			if remaining == 0 {
+				ret.resolve(NewListValue(values))
+			}
+			return nil
+		})
+	}
+	return ret, nil
+}
+
+// race returns a promise which is settled like the first settled promise
+func (loop *eventLoop) race(depth int, value Value) (Value, error) {
+	promises, err := loop.promises(depth, value)
+	if err != nil {
+		return nil, err
+	}
+	if len(promises) == 0 {
+		return nil, errors.New("race() expects at least one promise")
+	}
+	ret := NewPromiseValue(loop)
+	for _, promise := range promises {
+		promise.onSettled(func() error {
+			ret.settle(promise.result())
+			return nil
+		})
+	}
+	return ret, nil
+}
@@ -2,13 +2,185 @@ package main
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
 func clock(args []Value) (Value, error) {
-	if len(args) != 0 {
-		return nil, errors.New("clock() expects no arguments")
-	}
 	seconds := time.Now().Unix()
-	return NewNumValue(float64(seconds)), nil
+	return NewIntValue(seconds), nil
+}
+
+func rangeFn(args []Value) (Value, error) {
+	var bounds []float64
+	integral := true
+	for _, arg := range args {
+		if !isNumber(arg) {
+			return nil, fmt.Errorf("range() expects numbers but got %s", arg)
+		}
+		_, isInt := arg.(*IntValue)
+		integral = integral && isInt
+		bounds = append(bounds, toFloat(arg))
+	}
+
+	switch len(bounds) {
+	case 1:
+		return NewRangeValue(0, bounds[0], 1, integral), nil
+	case 2:
+		return NewRangeValue(bounds[0], bounds[1], 1, integral), nil
+	default:
+		if bounds[2] == 0 {
+			return nil, errors.New("range() step must not be zero")
+		}
+		return NewRangeValue(bounds[0], bounds[1], bounds[2], integral), nil
+	}
+}
+
+func lenFn(args []Value) (Value, error) {
+	value, ok := args[0].(sized)
+	if !ok {
+		return nil, fmt.Errorf("len() is not supported for %s", args[0])
+	}
+	return NewIntValue(int64(value.length())), nil
+}
+
+// maxFn returns the largest of its arguments, e.g. max(...numbers)
+func maxFn(args []Value) (Value, error) {
+	return extremum(args, func(a, b Value) (bool, error) {
+		return lessThan(b, a)
+	})
+}
+
+// minFn returns the smallest of its arguments
+func minFn(args []Value) (Value, error) {
+	return extremum(args, lessThan)
+}
+
+func extremum(args []Value, isBetter func(a, b Value) (bool, error)) (Value, error) {
+	ret := args[0]
+	for _, arg := range args[1:] {
+		better, err := isBetter(arg, ret)
+		if err != nil {
+			return nil, err
+		}
+		if better {
+			ret = arg
+		}
+	}
+	return ret, nil
+}
+
+// arityFn returns the number of arguments a callable requires. Parameters
+// with default values and rest parameters are optional.
+func arityFn(args []Value) (Value, error) {
+	switch fn := args[0].(type) {
+	case *BuiltinFuncValue:
+		return NewIntValue(int64(fn.minArity)), nil
+	case *LambdaValue, *ClassValue:
+		parameters, err := parametersOf(fn)
+		if err != nil {
+			return nil, err
+		}
+		required := 0
+		for _, param := range parameters {
+			if param.defaultValue == nil && !param.isRest {
+				required++
+			}
+		}
+		return NewIntValue(int64(required)), nil
+	default:
+		return nil, fmt.Errorf("arity() expects a callable but got %s", args[0])
+	}
+}
+
+// nameOfFn returns the name of a callable. Methods are named without their
+// class.
+func nameOfFn(args []Value) (Value, error) {
+	switch fn := args[0].(type) {
+	case *BuiltinFuncValue:
+		return NewStringValue(fn.name), nil
+	case *LambdaValue:
+		_, name, _ := strings.Cut(fn.name, "::")
+		if name == "" {
+			name = fn.name
+		}
+		return NewStringValue(name), nil
+	case *ClassValue:
+		return NewStringValue(fn.name), nil
+	default:
+		return nil, fmt.Errorf("nameOf() expects a callable but got %s", args[0])
+	}
+}
+
+// paramsFn returns the parameter names of a function or of the constructor of
+// a class. Rest parameters are prefixed with "...".
+func paramsFn(args []Value) (Value, error) {
+	if _, isBuiltin := args[0].(*BuiltinFuncValue); isBuiltin {
+		return nil, fmt.Errorf("params() is not available for builtin function %s", args[0])
+	}
+	parameters, err := parametersOf(args[0])
+	if err != nil {
+		return nil, err
+	}
+	names := make([]Value, len(parameters))
+	for i, param := range parameters {
+		name := param.name
+		if param.isRest {
+			name = "..." + name
+		}
+		names[i] = NewStringValue(name)
+	}
+	return NewListValue(names), nil
+}
+
+func isCallableFn(args []Value) (Value, error) {
+	_, ok := args[0].(callable)
+	return NewBooleanValue(ok), nil
+}
+
+func parametersOf(value Value) ([]Parameter, error) {
+	switch fn := value.(type) {
+	case *LambdaValue:
+		return fn.parameters, nil
+	case *ClassValue:
+		init, _, err := fn.findMethod("init")
+		if err != nil {
+			// classes without constructor take no arguments
+			return nil, nil
+		}
+		return init.parameters, nil
+	default:
+		return nil, fmt.Errorf("params() expects a function or class but got %s", value)
+	}
+}
+
+// memoizeFn wraps a function in a cache of its results, keyed by the
+// arguments. It is meant to be used as decorator, e.g. @memoize fun fib(n).
+func memoizeFn(args []Value) (Value, error) {
+	fn, ok := args[0].(callable)
+	if !ok {
+		return nil, fmt.Errorf("memoize() expects a callable but got %s", args[0])
+	}
+	name, err := nameOfFn(args)
+	if err != nil {
+		return nil, err
+	}
+	cache := newValueTable()
+	// the cached function runs one level below the memoized one
+	return NewBuiltinFuncValueAt(name.(*StringValue).Value, 0, variadic, func(depth int, args []Value) (Value, error) {
+		key := NewTupleValue(args)
+		entry, err := cache.lookup(depth, key)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			return entry.value, nil
+		}
+		ret, err := callAt(fn, depth+1, args, nil)
+		if err != nil {
+			return nil, err
+		}
+		return ret, cache.put(depth, key, ret)
+	}), nil
 }
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// BytesValue is an immutable sequence of bytes
+type BytesValue struct {
+	data []byte
+}
+
+func NewBytesValue(data []byte) *BytesValue {
+	return &BytesValue{data}
+}
+
+func (b *BytesValue) getType() ValueType {
+	return VtBytes
+}
+
+func (b *BytesValue) isEqualTo(value Value) bool {
+	other, ok := value.(*BytesValue)
+	return ok && bytes.Equal(b.data, other.data)
+}
+
+func (b *BytesValue) isTruthy() bool {
+	return true
+}
+
+// String shows printable ASCII characters as is and all other bytes as
+// hexadecimal escape sequences, e.g. b"GIF89a\x01\x00"
+func (b *BytesValue) String() string {
+	var ret strings.Builder
+	ret.WriteString(`b"`)
+	for _, c := range b.data {
+		switch {
+		case c == '"' || c == '\\':
+			ret.WriteByte('\\')
+			ret.WriteByte(c)
+		case c >= 0x20 && c < 0x7f:
+			ret.WriteByte(c)
+		default:
+			fmt.Fprintf(&ret, `\x%02x`, c)
+		}
+	}
+	ret.WriteString(`"`)
+	return ret.String()
+}
+
+func (b *BytesValue) length() int {
+	return len(b.data)
+}
+
+func (b *BytesValue) getIndex(index Value) (Value, error) {
+	pos, err := normalizeIndex(index, len(b.data))
+	if err != nil {
+		return nil, fmt.Errorf("bytes %w", err)
+	}
+	return NewIntValue(int64(b.data[pos])), nil
+}
+
+func (b *BytesValue) slice(start, end Value) (Value, error) {
+	from, to, err := sliceBounds(start, end, len(b.data))
+	if err != nil {
+		return nil, err
+	}
+	return NewBytesValue(b.data[from:to]), nil
+}
+
+func (b *BytesValue) iterate() (valueIterator, error) {
+	elements := make([]Value, len(b.data))
+	for i, c := range b.data {
+		elements[i] = NewIntValue(int64(c))
+	}
+	return &listIterator{list: NewListValue(elements)}, nil
+}
+
+func (b *BytesValue) concat(other *BytesValue) *BytesValue {
+	data := make([]byte, 0, len(b.data)+len(other.data))
+	return NewBytesValue(append(append(data, b.data...), other.data...))
+}
+
+func (b *BytesValue) getMember(name string) (Value, error) {
+	method, ok := bytesMethods[name]
+	if !ok {
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+	return NewBuiltinFuncValue(name, method.minArity, method.maxArity, func(args []Value) (Value, error) {
+		return method.fn(b, args)
+	}), nil
+}
+
+// bytesFn creates bytes either from a string and an optional encoding
+// (utf-8 by default) or from an iterable of integers between 0 and 255
+func bytesFn(depth int, args []Value) (Value, error) {
+	str, isString := args[0].(*StringValue)
+	if !isString {
+		if len(args) != 1 {
+			return nil, errors.New("bytes() expects an encoding only for strings")
+		}
+		return bytesFromIterable(depth, args[0])
+	}
+
+	encoding := "utf-8"
+	if len(args) == 2 {
+		encodingStr, ok := args[1].(*StringValue)
+		if !ok {
+			return nil, fmt.Errorf("bytes() expects the name of an encoding but got %s", args[1])
+		}
+		encoding = encodingStr.Value
+	}
+	data, err := encode(str.Value, encoding)
+	if err != nil {
+		return nil, err
+	}
+	return NewBytesValue(data), nil
+}
+
+func bytesFromIterable(depth int, value Value) (Value, error) {
+	iterator, err := iterate(depth, value)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for {
+		hasNext, err := iterator.hasNext()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			return NewBytesValue(data), nil
+		}
+		element, err := iterator.next()
+		if err != nil {
+			return nil, err
+		}
+		c, err := valueToInt(element)
+		if err != nil || c < 0 || c > 255 {
+			return nil, fmt.Errorf("byte value must be an integer between 0 and 255 but got %s", element)
+		}
+		data = append(data, byte(c))
+	}
+}
+
+// encode converts a string to bytes. Besides character encodings the binary
+// to text encodings hex and base64 are supported.
+func encode(str string, encoding string) ([]byte, error) {
+	switch strings.ToLower(encoding) {
+	case "utf-8", "utf8":
+		return []byte(str), nil
+	case "latin-1", "latin1", "ascii":
+		limit := rune(0xff)
+		if strings.ToLower(encoding) == "ascii" {
+			limit = 0x7f
+		}
+		var data []byte
+		for _, r := range str {
+			if r > limit {
+				return nil, fmt.Errorf("character %q cannot be encoded as %s", r, encoding)
+			}
+			data = append(data, byte(r))
+		}
+		return data, nil
+	case "hex":
+		data, err := hex.DecodeString(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex string: %w", err)
+		}
+		return data, nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 string: %w", err)
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %s", encoding)
+	}
+}
+
+// bytesMethod is a method of bytes, which is called with between minArity and
+// maxArity arguments
+type bytesMethod struct {
+	minArity int
+	maxArity int
+	fn       func(b *BytesValue, args []Value) (Value, error)
+}
+
+var bytesMethods = map[string]bytesMethod{
+	"hex":    {0, 0, bytesHex},
+	"base64": {0, 0, bytesBase64},
+	"decode": {0, 1, bytesDecode},
+}
+
+func bytesHex(b *BytesValue, args []Value) (Value, error) {
+	return NewStringValue(hex.EncodeToString(b.data)), nil
+}
+
+func bytesBase64(b *BytesValue, args []Value) (Value, error) {
+	return NewStringValue(base64.StdEncoding.EncodeToString(b.data)), nil
+}
+
+// bytesDecode converts the bytes to a string using the given character
+// encoding, utf-8 by default
+func bytesDecode(b *BytesValue, args []Value) (Value, error) {
+	encoding := "utf-8"
+	if len(args) == 1 {
+		encodingStr, ok := args[0].(*StringValue)
+		if !ok {
+			return nil, fmt.Errorf("decode() expects the name of an encoding but got %s", args[0])
+		}
+		encoding = encodingStr.Value
+	}
+
+	switch strings.ToLower(encoding) {
+	case "utf-8", "utf8":
+		if !utf8.Valid(b.data) {
+			return nil, errors.New("bytes are not valid utf-8")
+		}
+		return NewStringValue(string(b.data)), nil
+	case "latin-1", "latin1", "ascii":
+		runes := make([]rune, len(b.data))
+		for i, c := range b.data {
+			if c > 0x7f && strings.ToLower(encoding) == "ascii" {
+				return nil, fmt.Errorf("byte 0x%02x cannot be decoded as ascii", c)
+			}
+			runes[i] = rune(c)
+		}
+		return NewStringValue(string(runes)), nil
+	default:
+		return nil, fmt.Errorf("unknown encoding %s", encoding)
+	}
+}
@@ -0,0 +1,408 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// deadlockCheckInterval is how long all tasks of a program have to be blocked
+// without any progress before a deadlock is reported. It gives tasks which are
+// about to block on matching operations the time to meet.
+const deadlockCheckInterval = 10 * time.Millisecond
+
+// taskScheduler detects deadlocks among the tasks of a program. The main
+// program and every spawned task are live tasks. Generator bodies and async
+// functions run on behalf of the task consuming them. If all live tasks are
+// blocked on channels, joins or wait groups, all of them are aborted.
+type taskScheduler struct {
+	live    int
+	blocked int
+	version int           // changed whenever a task starts, finishes, blocks or resumes
+	abort   chan struct{} // closed to abort all blocked operations on a deadlock
+	mu      sync.Mutex
+}
+
+func newTaskScheduler() *taskScheduler {
+	return &taskScheduler{live: 1, abort: make(chan struct{})}
+}
+
+// update changes the number of live and blocked tasks. It returns the
+// channel which is closed when the blocked operations are aborted.
+func (s *taskScheduler) update(live, blocked int) chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.live += live
+	s.blocked += blocked
+	s.version++
+	return s.abort
+}
+
+// wait blocks the current task until one of the cases can proceed, see
+// reflect.Select. If the operation can never proceed because all tasks are
+// blocked, errDeadlock is returned.
+func (s *taskScheduler) wait(cases []reflect.SelectCase, errDeadlock error) (int, reflect.Value, bool, error) {
+	numCases := len(cases)
+	cases = append(cases[:numCases:numCases], reflect.SelectCase{Dir: reflect.SelectDefault})
+	chosen, received, ok := reflect.Select(cases)
+	if chosen < numCases {
+		return chosen, received, ok, nil
+	}
+
+	abort := s.update(0, 1)
+	defer s.update(0, -1)
+	ticker := time.NewTicker(deadlockCheckInterval)
+	defer ticker.Stop()
+	cases[numCases] = reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(abort)}
+	cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ticker.C)})
+	seen := -1
+	for {
+		chosen, received, ok = reflect.Select(cases)
+		switch {
+		case chosen < numCases:
+			return chosen, received, ok, nil
+		case chosen == numCases || s.isDeadlocked(&seen):
+			return -1, reflect.Value{}, false, errDeadlock
+		}
+	}
+}
+
+// isDeadlocked tells whether all tasks have been blocked since the version
+// seen by the previous check. In this case all blocked operations are aborted.
+func (s *taskScheduler) isDeadlocked(seen *int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.blocked < s.live {
+		*seen = -1
+		return false
+	}
+	if s.version != *seen {
+		*seen = s.version
+		return false
+	}
+	close(s.abort)
+	s.abort = make(chan struct{})
+	return true
+}
+
+// initTaskBuiltins defines the builtins creating channels and wait groups,
+// which are bound to the scheduler of the program
+func initTaskBuiltins(values map[string]Value, tasks *taskScheduler) {
+	values["channel"] = NewBuiltinFuncValue("channel", 0, 1, func(args []Value) (Value, error) {
+		return channelFn(tasks, args)
+	})
+	values["waitGroup"] = NewBuiltinFuncValue("waitGroup", 0, 0, func([]Value) (Value, error) {
+		return NewWaitGroupValue(tasks), nil
+	})
+}
+
+// TaskValue is the handle of a function call running on its own goroutine,
+// started by spawn f(args)
+type TaskValue struct {
+	name   string
+	tasks  *taskScheduler
+	done   chan struct{}
+	result Value
+	err    error
+}
+
+func spawn(tasks *taskScheduler, fn callable, args []Value, named []namedArg) *TaskValue {
+	task := &TaskValue{name: fmt.Sprint(fn), tasks: tasks, done: make(chan struct{})}
+	tasks.update(1, 0)
+	go func() {
+		defer tasks.update(-1, 0)
+		defer close(task.done)
+		task.result, task.err = callWithNamed(fn, args, named)
+	}()
+	return task
+}
+
+func (t *TaskValue) getType() ValueType {
+	return VtTask
+}
+
+func (t *TaskValue) isEqualTo(value Value) bool {
+	other, ok := value.(*TaskValue)
+	return ok && t == other
+}
+
+func (t *TaskValue) isTruthy() bool {
+	return true
+}
+
+func (t *TaskValue) String() string {
+	return fmt.Sprintf("<task %s>", t.name)
+}
+
+// join waits for the task to finish and returns its result. A runtime error
+// of the task is raised again in the joining task.
+func (t *TaskValue) join() (Value, error) {
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(t.done)}}
+	_, _, _, err := t.tasks.wait(cases, errors.New("deadlock: joined task can never finish"))
+	if err != nil {
+		return nil, err
+	}
+	return t.result, t.err
+}
+
+func (t *TaskValue) getMember(name string) (Value, error) {
+	switch name {
+	case "join":
+		return NewBuiltinFuncValue("join", 0, 0, func([]Value) (Value, error) {
+			return t.join()
+		}), nil
+	case "done":
+		select {
+		case <-t.done:
+			return NewBooleanValue(true), nil
+		default:
+			return NewBooleanValue(false), nil
+		}
+	default:
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+}
+
+// ChannelValue passes values between tasks. Unbuffered channels block the
+// sender until the value is received.
+type ChannelValue struct {
+	tasks   *taskScheduler
+	ch      chan Value
+	closing chan struct{} // closed first to abort blocked senders
+	once    sync.Once
+	mu      sync.RWMutex // held for reading by senders, so ch is closed after they left
+}
+
+var (
+	errSendOnClosed = errors.New("send on closed channel")
+	errNoReceiver   = errors.New("deadlock: no task can receive from channel")
+	errNoSender     = errors.New("deadlock: no task can send on channel")
+)
+
+func NewChannelValue(tasks *taskScheduler, capacity int) *ChannelValue {
+	return &ChannelValue{tasks: tasks, ch: make(chan Value, capacity), closing: make(chan struct{})}
+}
+
+func (c *ChannelValue) getType() ValueType {
+	return VtChannel
+}
+
+func (c *ChannelValue) isEqualTo(value Value) bool {
+	other, ok := value.(*ChannelValue)
+	return ok && c == other
+}
+
+func (c *ChannelValue) isTruthy() bool {
+	return true
+}
+
+func (c *ChannelValue) String() string {
+	return fmt.Sprintf("<channel %d/%d>", len(c.ch), cap(c.ch))
+}
+
+func (c *ChannelValue) length() int {
+	return len(c.ch)
+}
+
+func (c *ChannelValue) send(value Value) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	select {
+	case <-c.closing:
+		return errSendOnClosed
+	default:
+	}
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectSend, Chan: reflect.ValueOf(c.ch), Send: reflect.ValueOf(&value).Elem()},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.closing)},
+	}
+	chosen, _, _, err := c.tasks.wait(cases, errNoReceiver)
+	if err == nil && chosen == 1 {
+		return errSendOnClosed
+	}
+	return err
+}
+
+// recv blocks until a value is available. On a closed and drained channel it
+// returns nil and false.
+func (c *ChannelValue) recv() (Value, bool, error) {
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.ch)}}
+	_, value, ok, err := c.tasks.wait(cases, errNoSender)
+	if err != nil || !ok {
+		return NewNilValue(), false, err
+	}
+	return value.Interface().(Value), true, nil
+}
+
+func (c *ChannelValue) close() error {
+	closed := false
+	c.once.Do(func() {
+		close(c.closing)
+		closed = true
+	})
+	if !closed {
+		return errors.New("close of closed channel")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.ch)
+	return nil
+}
+
+// iterate receives values until the channel is closed
+func (c *ChannelValue) iterate() (valueIterator, error) {
+	return &channelIterator{channel: c}, nil
+}
+
+func (c *ChannelValue) getMember(name string) (Value, error) {
+	switch name {
+	case "send":
+		return NewBuiltinFuncValue("send", 1, 1, func(args []Value) (Value, error) {
+			return NewNilValue(), c.send(args[0])
+		}), nil
+	case "recv":
+		return NewBuiltinFuncValue("recv", 0, 0, func([]Value) (Value, error) {
+			value, _, err := c.recv()
+			return value, err
+		}), nil
+	case "close":
+		return NewBuiltinFuncValue("close", 0, 0, func([]Value) (Value, error) {
+			return NewNilValue(), c.close()
+		}), nil
+	default:
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+}
+
+type channelIterator struct {
+	channel  *ChannelValue
+	buffered Value
+	finished bool
+}
+
+func (it *channelIterator) hasNext() (bool, error) {
+	if it.buffered == nil && !it.finished {
+		value, ok, err := it.channel.recv()
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			it.buffered = value
+		} else {
+			it.finished = true
+		}
+	}
+	return it.buffered != nil, nil
+}
+
+func (it *channelIterator) next() (Value, error) {
+	hasNext, err := it.hasNext()
+	if err != nil {
+		return nil, err
+	}
+	if !hasNext {
+		return nil, errors.New("channel is closed")
+	}
+	ret := it.buffered
+	it.buffered = nil
+	return ret, nil
+}
+
+// channelFn creates a channel with an optional buffer capacity
+func channelFn(tasks *taskScheduler, args []Value) (Value, error) {
+	capacity := 0
+	if len(args) == 1 {
+		var err error
+		capacity, err = valueToInt(args[0])
+		if err != nil || capacity < 0 {
+			return nil, fmt.Errorf("channel() expects a non-negative capacity but got %s", args[0])
+		}
+	}
+	return NewChannelValue(tasks, capacity), nil
+}
+
+// WaitGroupValue waits for a number of tasks to call done()
+type WaitGroupValue struct {
+	tasks *taskScheduler
+	count int
+	zero  chan struct{} // closed while the counter is zero
+	mu    sync.Mutex
+}
+
+func NewWaitGroupValue(tasks *taskScheduler) *WaitGroupValue {
+	zero := make(chan struct{})
+	close(zero)
+	return &WaitGroupValue{tasks: tasks, zero: zero}
+}
+
+func (w *WaitGroupValue) getType() ValueType {
+	return VtWaitGroup
+}
+
+func (w *WaitGroupValue) isEqualTo(value Value) bool {
+	other, ok := value.(*WaitGroupValue)
+	return ok && w == other
+}
+
+func (w *WaitGroupValue) isTruthy() bool {
+	return true
+}
+
+func (w *WaitGroupValue) String() string {
+	return "<wait group>"
+}
+
+func (w *WaitGroupValue) getMember(name string) (Value, error) {
+	switch name {
+	case "add":
+		return NewBuiltinFuncValue("add", 0, 1, func(args []Value) (Value, error) {
+			delta := 1
+			if len(args) == 1 {
+				var err error
+				delta, err = valueToInt(args[0])
+				if err != nil {
+					return nil, err
+				}
+			}
+			return NewNilValue(), w.add(delta)
+		}), nil
+	case "done":
+		return NewBuiltinFuncValue("done", 0, 0, func([]Value) (Value, error) {
+			return NewNilValue(), w.add(-1)
+		}), nil
+	case "wait":
+		return NewBuiltinFuncValue("wait", 0, 0, func([]Value) (Value, error) {
+			return NewNilValue(), w.wait()
+		}), nil
+	default:
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+}
+
+func (w *WaitGroupValue) add(delta int) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count+delta < 0 {
+		return errors.New("negative wait group counter")
+	}
+	if w.count == 0 && delta > 0 {
+		w.zero = make(chan struct{})
+	}
+	w.count += delta
+	if w.count == 0 && delta < 0 {
+		close(w.zero)
+	}
+	return nil
+}
+
+// wait blocks until the counter is zero
+func (w *WaitGroupValue) wait() error {
+	w.mu.Lock()
+	zero := w.zero
+	w.mu.Unlock()
+	cases := []reflect.SelectCase{{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(zero)}}
+	_, _, _, err := w.tasks.wait(cases, errors.New("deadlock: wait group counter can never reach zero"))
+	return err
+}
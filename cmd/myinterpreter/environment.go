@@ -3,32 +3,83 @@ package main
 import (
 	"errors"
 	"fmt"
+	"math"
+	"sync"
 )
 
+// Environment holds the variables of a scope. It can be shared by spawned
+// tasks, so all accesses of values are guarded by mu. Copies of an environment
+// share values and mu.
 type Environment struct {
 	parent *Environment
 	values map[string]Value
+	mu     *sync.RWMutex
+	loop   *eventLoop     // only set in the global environment
+	tasks  *taskScheduler // only set in the global environment
 }
 
 func NewEnvironment(parent *Environment) *Environment {
 	values := make(map[string]Value)
 
+	var loop *eventLoop
+	var tasks *taskScheduler
 	if parent == nil {
+		loop = newEventLoop()
+		tasks = newTaskScheduler()
 		initBuiltins(values)
+		initLoopBuiltins(values, loop)
+		initTaskBuiltins(values, tasks)
 	}
 
 	return &Environment{
 		parent: parent,
 		values: values,
+		mu:     &sync.RWMutex{},
+		loop:   loop,
+		tasks:  tasks,
 	}
 }
 
 func initBuiltins(values map[string]Value) {
-	values["clock"] = NewBuiltinFuncValue("clock", clock)
+	values["NaN"] = NewNumValue(math.NaN())
+	values["Infinity"] = NewNumValue(math.Inf(1))
+	values["clock"] = NewBuiltinFuncValue("clock", 0, 0, clock)
+	values["range"] = NewBuiltinFuncValue("range", 1, 3, rangeFn)
+	values["len"] = NewBuiltinFuncValue("len", 1, 1, lenFn)
+	values["max"] = NewBuiltinFuncValue("max", 1, variadic, maxFn)
+	values["min"] = NewBuiltinFuncValue("min", 1, variadic, minFn)
+	values["int"] = NewBuiltinFuncValue("int", 1, 1, intFn)
+	values["float"] = NewBuiltinFuncValue("float", 1, 1, floatFn)
+	values["set"] = NewBuiltinFuncValueAt("set", 0, 1, setFn)
+	values["bytes"] = NewBuiltinFuncValueAt("bytes", 1, 2, bytesFn)
+	values["arity"] = NewBuiltinFuncValue("arity", 1, 1, arityFn)
+	values["nameOf"] = NewBuiltinFuncValue("nameOf", 1, 1, nameOfFn)
+	values["params"] = NewBuiltinFuncValue("params", 1, 1, paramsFn)
+	values["isCallable"] = NewBuiltinFuncValue("isCallable", 1, 1, isCallableFn)
+	values["memoize"] = NewBuiltinFuncValue("memoize", 1, 1, memoizeFn)
+}
+
+// eventLoop returns the event loop of the program the environment belongs to
+func (env *Environment) eventLoop() *eventLoop {
+	for env.parent != nil {
+		env = env.parent
+	}
+	return env.loop
+}
+
+// scheduler returns the scheduler of the tasks of the program the environment
+// belongs to
+func (env *Environment) scheduler() *taskScheduler {
+	for env.parent != nil {
+		env = env.parent
+	}
+	return env.tasks
 }
 
 func (env *Environment) Get(name string) (Value, error) {
+	env.mu.RLock()
 	value, ok := env.values[name]
+	env.mu.RUnlock()
 	if ok {
 		if value != nil {
 			return value, nil
@@ -44,7 +95,9 @@ func (env *Environment) Get(name string) (Value, error) {
 }
 
 func (env *Environment) GetDefiningEnv(name string) (*Environment, error) {
+	env.mu.RLock()
 	_, ok := env.values[name]
+	env.mu.RUnlock()
 	if ok {
 		return env, nil
 	}
@@ -68,10 +121,14 @@ func (env *Environment) GetEnvAtLevel(level int) (*Environment, error) {
 }
 
 func (env *Environment) Set(name string, value Value) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
 	env.values[name] = value
 }
 
 func (env *Environment) StartDeclaration(name string) {
+	env.mu.Lock()
+	defer env.mu.Unlock()
 	_, ok := env.values[name]
 	if ok {
 		return
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+)
+
+// errGeneratorClosed unwinds the body of a generator which is not consumed
+// any more. Deferred calls of the body are still run.
+var errGeneratorClosed = errors.New("generator has been closed")
+
+// generatorResult is sent from the generator body to the consumer on every
+// yield and once when the body has been finished
+type generatorResult struct {
+	value    Value
+	finished bool
+	err      error
+}
+
+// GeneratorValue is returned by calls of generator functions. The body of the
+// generator runs on its own goroutine with its own interpreter, so that it can
+// be suspended at any yield statement, however deeply nested in blocks and
+// loops. Consumer and body hand over control via channels, thus only one of
+// them is running at any time.
+type GeneratorValue struct {
+	body     *generatorBody
+	depth    int // call depth the body runs at
+	started  bool
+	finished bool
+	buffered *Value // value yielded but not yet consumed by next()
+	err      error
+	mu       sync.Mutex // serializes consumers running on different tasks
+}
+
+// generatorBody is the part of a generator used by the goroutine running the
+// body. It does not refer to the GeneratorValue, so that an abandoned
+// generator can be garbage collected, which stops the goroutine.
+type generatorBody struct {
+	lambda  *LambdaValue
+	callEnv *Environment
+	results chan generatorResult
+	resume  chan struct{}
+	cancel  chan struct{} // closed when the generator is not consumed any more
+	done    chan struct{} // closed when the body has been finished
+	once    sync.Once
+}
+
+func NewGeneratorValue(lambda *LambdaValue, callEnv *Environment, depth int) *GeneratorValue {
+	body := &generatorBody{
+		lambda:  lambda,
+		callEnv: callEnv,
+		results: make(chan generatorResult),
+		resume:  make(chan struct{}),
+		cancel:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	ret := &GeneratorValue{body: body, depth: depth}
+	runtime.SetFinalizer(ret, func(g *GeneratorValue) {
+		g.body.stop()
+	})
+	return ret
+}
+
+func (g *GeneratorValue) getType() ValueType {
+	return VtGenerator
+}
+
+func (g *GeneratorValue) isEqualTo(value Value) bool {
+	other, ok := value.(*GeneratorValue)
+	return ok && g == other
+}
+
+func (g *GeneratorValue) isTruthy() bool {
+	return true
+}
+
+func (g *GeneratorValue) String() string {
+	return fmt.Sprintf("<generator %s>", g.body.lambda.name)
+}
+
+func (g *GeneratorValue) getMember(name string) (Value, error) {
+	switch name {
+	case "next":
+		return NewBuiltinFuncValue("next", 0, 0, func(args []Value) (Value, error) {
+			return g.next()
+		}), nil
+	case "done":
+		done, err := g.isDone()
+		if err != nil {
+			return nil, err
+		}
+		return NewBooleanValue(done), nil
+	default:
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+}
+
+func (g *GeneratorValue) iterate() (valueIterator, error) {
+	return &generatorIterator{g}, nil
+}
+
+// next returns the next yielded value or nil if the generator is exhausted
+func (g *GeneratorValue) next() (Value, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	done, err := g.poll()
+	if err != nil {
+		return nil, err
+	}
+	if done {
+		return NewNilValue(), nil
+	}
+	ret := *g.buffered
+	g.buffered = nil
+	return ret, nil
+}
+
+// isDone runs the generator body up to the next yield statement (if that has
+// not happened yet) to find out whether another value is available
+func (g *GeneratorValue) isDone() (bool, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.poll()
+}
+
+func (g *GeneratorValue) poll() (bool, error) {
+	if g.buffered == nil && !g.finished {
+		g.advance()
+	}
+	if g.err != nil {
+		err := g.err
+		g.err = nil
+		return true, err
+	}
+	return g.buffered == nil, nil
+}
+
+func (g *GeneratorValue) advance() {
+	if !g.started {
+		g.started = true
+		go g.body.run(g.depth)
+	} else {
+		g.body.resume <- struct{}{}
+	}
+
+	result := <-g.body.results
+	if result.finished {
+		g.finished = true
+		g.err = result.err
+	} else {
+		g.buffered = &result.value
+	}
+}
+
+// close stops a generator whose remaining values are not needed. A suspended
+// body is unwound and its deferred calls are run before close returns.
+func (g *GeneratorValue) close() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.finished {
+		return
+	}
+	g.finished = true
+	g.buffered = nil
+	g.body.stop()
+	if g.started {
+		<-g.body.done
+	}
+}
+
+func (body *generatorBody) stop() {
+	body.once.Do(func() {
+		close(body.cancel)
+	})
+}
+
+func (body *generatorBody) run(depth int) {
+	defer close(body.done)
+	interpreter := NewInterpreter(body.callEnv)
+	interpreter.callDepth = depth
+	interpreter.lambdaEvalActive = true
+	interpreter.generator = body
+
+	interpreter.visitBlock(&body.lambda.body)
+	interpreter.runDeferred()
+
+	select {
+	case body.results <- generatorResult{finished: true, err: interpreter.lastError}:
+	case <-body.cancel:
+	}
+}
+
+// yield is called by the interpreter running the generator body. It hands
+// over the value to the consumer and blocks until the next value is requested.
+// If the generator is closed meanwhile, errGeneratorClosed is returned.
+func (body *generatorBody) yield(value Value) error {
+	select {
+	case body.results <- generatorResult{value: value}:
+	case <-body.cancel:
+		return errGeneratorClosed
+	}
+	select {
+	case <-body.resume:
+		return nil
+	case <-body.cancel:
+		return errGeneratorClosed
+	}
+}
+
+type generatorIterator struct {
+	generator *GeneratorValue
+}
+
+func (it *generatorIterator) close() {
+	it.generator.close()
+}
+
+func (it *generatorIterator) hasNext() (bool, error) {
+	done, err := it.generator.isDone()
+	return !done, err
+}
+
+func (it *generatorIterator) next() (Value, error) {
+	return it.generator.next()
+}
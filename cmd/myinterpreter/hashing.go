@@ -0,0 +1,273 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// hashKey identifies a value in hash based collections. Values which are
+// equal according to isEqualTo have the same hash key.
+type hashKey struct {
+	valueType ValueType
+	repr      string
+}
+
+// hashValue computes the hash key of strings, numbers, booleans, nil, bytes and
+// tuples of hashable values.
+// Instances are hashable if their class defines a hash() method returning a
+// hashable value. As different instances may have the same hash, they are told
+// apart by valuesEqual. Their methods are called at the given call depth.
+func hashValue(depth int, value Value) (hashKey, error) {
+	switch v := value.(type) {
+	case *StringValue:
+		return hashKey{VtString, v.Value}, nil
+	case *NumValue:
+		if v.Value == 0 {
+			return hashKey{VtNumber, "0"}, nil // -0 == 0
+		}
+		if v.Value == math.Trunc(v.Value) && !math.IsInf(v.Value, 0) {
+			return hashKey{VtNumber, strconv.FormatFloat(v.Value, 'f', -1, 64)}, nil // same as integers
+		}
+		return hashKey{VtNumber, strconv.FormatFloat(v.Value, 'g', -1, 64)}, nil
+	case *IntValue:
+		return hashKey{VtNumber, v.String()}, nil
+	case *BooleanValue:
+		return hashKey{VtBoolean, v.String()}, nil
+	case *NilValue:
+		return hashKey{VtNil, ""}, nil
+	case *BytesValue:
+		return hashKey{VtBytes, string(v.data)}, nil
+	case *TupleValue:
+		var repr strings.Builder
+		for _, element := range v.elements {
+			key, err := hashValue(depth, element)
+			if err != nil {
+				return hashKey{}, err
+			}
+			fmt.Fprintf(&repr, "%d:%d:%s;", key.valueType, len(key.repr), key.repr)
+		}
+		return hashKey{VtTuple, repr.String()}, nil
+	case *InstanceValue:
+		method, err := v.getMethod(depth, "hash")
+		if err != nil {
+			return hashKey{}, fmt.Errorf("instance of class %s is not hashable", v.class.name)
+		}
+		hash, err := callAt(method, depth, nil, nil)
+		if err != nil {
+			return hashKey{}, err
+		}
+		if _, isInstance := hash.(*InstanceValue); isInstance {
+			return hashKey{}, fmt.Errorf("hash() of class %s must not return an instance", v.class.name)
+		}
+		key, err := hashValue(depth, hash)
+		if err != nil {
+			return hashKey{}, err
+		}
+		return hashKey{VtInstance, v.class.name + ":" + key.repr}, nil
+	default:
+		return hashKey{}, fmt.Errorf("value %s is not hashable", value)
+	}
+}
+
+// valuesEqual implements ==, which is also used to tell apart keys of hash
+// tables with the same hash key. Instances, also within tuples, are compared by
+// their equals() method called at the given call depth, or by identity if their
+// class does not define one.
+func valuesEqual(depth int, key, other Value) (bool, error) {
+	if tuple, isTuple := key.(*TupleValue); isTuple {
+		otherTuple, isTuple := other.(*TupleValue)
+		if !isTuple || len(tuple.elements) != len(otherTuple.elements) {
+			return false, nil
+		}
+		for i, element := range tuple.elements {
+			equal, err := valuesEqual(depth, element, otherTuple.elements[i])
+			if err != nil || !equal {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	instance, isInstance := key.(*InstanceValue)
+	if !isInstance {
+		return key.isEqualTo(other), nil
+	}
+	otherInstance, isInstance := other.(*InstanceValue)
+	if !isInstance {
+		return false, nil
+	}
+	if instance == otherInstance {
+		return true, nil
+	}
+	method, err := instance.getMethod(depth, "equals")
+	if err != nil {
+		return false, nil
+	}
+	equal, err := callAt(method, depth, []Value{other}, nil)
+	if err != nil {
+		return false, err
+	}
+	return equal.isTruthy(), nil
+}
+
+type tableEntry struct {
+	key     Value
+	value   Value
+	deleted bool
+}
+
+// valueTable is a hash table which keeps its entries in insertion order. Keys
+// with the same hash key are told apart by valuesEqual. The table is safe for
+// concurrent use, entries are handed out as copies.
+type valueTable struct {
+	entries    []*tableEntry
+	buckets    map[hashKey][]*tableEntry
+	count      int
+	numDeleted int
+	version    int // changed whenever entries are added or removed
+	mu         sync.RWMutex
+}
+
+func newValueTable() *valueTable {
+	return &valueTable{buckets: make(map[hashKey][]*tableEntry)}
+}
+
+func (t *valueTable) size() int {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.count
+}
+
+// find returns the entry of key and the version of the table the entry was
+// found in. The entry is nil if the key is not in the table. Comparing keys may
+// run Lox code at the given call depth, so it is done without holding the lock.
+// Callers modifying the table have to check that the version is unchanged.
+func (t *valueTable) find(depth int, hash hashKey, key Value) (*tableEntry, int, error) {
+	t.mu.RLock()
+	bucket := slices.Clone(t.buckets[hash])
+	version := t.version
+	t.mu.RUnlock()
+	for _, entry := range bucket {
+		equal, err := valuesEqual(depth, entry.key, key)
+		if err != nil {
+			return nil, 0, err
+		}
+		if equal {
+			return entry, version, nil
+		}
+	}
+	return nil, version, nil
+}
+
+func (t *valueTable) lookup(depth int, key Value) (*tableEntry, error) {
+	// hashing may run Lox code, so it is done before locking
+	hash, err := hashValue(depth, key)
+	if err != nil {
+		return nil, err
+	}
+	found, _, err := t.find(depth, hash, key)
+	if err != nil || found == nil {
+		return nil, err
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	entry := *found
+	return &entry, nil
+}
+
+func (t *valueTable) put(depth int, key Value, value Value) error {
+	hash, err := hashValue(depth, key)
+	if err != nil {
+		return err
+	}
+	for {
+		found, version, err := t.find(depth, hash, key)
+		if err != nil {
+			return err
+		}
+		t.mu.Lock()
+		if t.version != version {
+			t.mu.Unlock()
+			continue // modified concurrently
+		}
+		if found != nil {
+			found.value = value
+		} else {
+			entry := &tableEntry{key: key, value: value}
+			t.entries = append(t.entries, entry)
+			t.buckets[hash] = append(t.buckets[hash], entry)
+			t.count++
+			t.version++
+		}
+		t.mu.Unlock()
+		return nil
+	}
+}
+
+func (t *valueTable) remove(depth int, key Value) (bool, error) {
+	hash, err := hashValue(depth, key)
+	if err != nil {
+		return false, err
+	}
+	for {
+		found, version, err := t.find(depth, hash, key)
+		if err != nil || found == nil {
+			return false, err
+		}
+		t.mu.Lock()
+		if t.version != version {
+			t.mu.Unlock()
+			continue // modified concurrently
+		}
+		t.removeEntry(hash, found)
+		t.mu.Unlock()
+		return true, nil
+	}
+}
+
+// removeEntry removes an entry of the bucket of hash. The caller has to hold
+// the lock.
+func (t *valueTable) removeEntry(hash hashKey, found *tableEntry) {
+	bucket := t.buckets[hash]
+	pos := slices.Index(bucket, found)
+	bucket[pos].deleted = true
+	if len(bucket) == 1 {
+		delete(t.buckets, hash)
+	} else {
+		t.buckets[hash] = append(bucket[:pos:pos], bucket[pos+1:]...)
+	}
+	t.count--
+	t.numDeleted++
+	t.version++
+	if t.numDeleted > len(t.entries)/2 {
+		t.compact()
+	}
+}
+
+func (t *valueTable) compact() {
+	var entries []*tableEntry
+	for _, entry := range t.entries {
+		if !entry.deleted {
+			entries = append(entries, entry)
+		}
+	}
+	t.entries = entries
+	t.numDeleted = 0
+}
+
+// liveEntries returns copies of the entries in insertion order
+func (t *valueTable) liveEntries() []*tableEntry {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	ret := make([]*tableEntry, 0, t.count)
+	for _, entry := range t.entries {
+		if !entry.deleted {
+			copied := *entry
+			ret = append(ret, &copied)
+		}
+	}
+	return ret
+}
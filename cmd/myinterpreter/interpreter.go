@@ -3,13 +3,48 @@ package main
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
 )
 
+type controlFlowKind int
+
+const (
+	cfNone controlFlowKind = iota
+	cfReturn
+	cfBreak
+	cfContinue
+)
+
+// controlFlow signals that the regular execution of statements has been
+// interrupted by a return, break or continue statement
+type controlFlow struct {
+	kind  controlFlowKind
+	label string // target loop of break and continue, empty for the innermost loop
+}
+
+// deferredCall is a call whose callee and arguments are already evaluated. It
+// is registered by a defer statement and executed when the enclosing function
+// returns, or by a tail call which is executed by the caller of the function.
+type deferredCall struct {
+	fn    callable
+	args  []Value
+	named []namedArg
+}
+
+// Interpreter evaluates the AST. Its state is confined to a single goroutine,
+// every function call and every spawned task uses an interpreter of its own.
 type Interpreter struct {
 	lastResult       Value
 	lastError        error
 	lambdaEvalActive bool
-	returnOccurred   bool
+	controlFlow      controlFlow
+	generator        *generatorBody // set while the body of a generator is executed
+	task             *asyncTask     // set while the body of an async function is executed
+	deferred         []deferredCall
+	tailCall         *deferredCall
+	callDepth        int // number of active function calls
 	env              *Environment
 }
 
@@ -32,6 +67,10 @@ func (interpreter *Interpreter) Run(code string) (error, bool) {
 		return err, false
 	}
 	ast.accept(interpreter)
+	if interpreter.lastError == nil {
+		// asynchronous tasks and timers started by the program
+		interpreter.lastError = interpreter.env.eventLoop().run()
+	}
 	return interpreter.lastError, interpreter.lastError != nil
 }
 
@@ -43,6 +82,10 @@ func (interpreter *Interpreter) Eval(code string) (Value, error, bool) {
 	}
 	var value Value
 	value, err = interpreter.evalAst(ast)
+	if err == nil {
+		// asynchronous tasks and timers started by the expression
+		err = interpreter.env.eventLoop().run()
+	}
 	return value, err, err != nil
 }
 
@@ -59,9 +102,16 @@ func (interpreter *Interpreter) visitBlock(block *Block) {
 	blockEnv := NewEnvironment(interpreter.env)
 	interpreter.env = blockEnv
 
+	// functions are hoisted, so that calls before their declaration do not
+	// reach functions of the same name in enclosing scopes
+	for _, statement := range block.statements {
+		if function, isFunction := statement.(*FunctionDef); isFunction {
+			blockEnv.StartDeclaration(function.name)
+		}
+	}
 	for _, statement := range block.statements {
 		statement.accept(interpreter)
-		if interpreter.lastError != nil || interpreter.returnOccurred {
+		if interpreter.lastError != nil || interpreter.interrupted() {
 			break
 		}
 	}
@@ -78,6 +128,40 @@ func (interpreter *Interpreter) visitVarDecl(varDecl *VarDecl) {
 	interpreter.env.Set(varDecl.name, value) // finalize declaration
 }
 
+func (interpreter *Interpreter) visitDestructuringDecl(decl *DestructuringDecl) {
+	for _, name := range decl.pattern.names() {
+		interpreter.env.StartDeclaration(name)
+	}
+	value, err := interpreter.evalAst(decl.expression)
+	if err != nil {
+		return
+	}
+	interpreter.bindPattern(decl.pattern, value)
+}
+
+// bindPattern defines the variables of a destructuring declaration
+func (interpreter *Interpreter) bindPattern(pattern *TupleExpr, value Value) {
+	values, err := unpack(interpreter.callDepth+1, value, len(pattern.elements))
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return
+	}
+	for i, element := range pattern.elements {
+		switch target := element.(type) {
+		case *IdentifierExpr:
+			interpreter.env.Set(target.name, values[i])
+		case *TupleExpr:
+			interpreter.bindPattern(target, values[i])
+			if interpreter.lastError != nil {
+				return
+			}
+		}
+	}
+	interpreter.lastResult = value
+	interpreter.lastError = nil
+}
+
 func (interpreter *Interpreter) visitPrint(printStmt *PrintStatement) {
 	value, err := interpreter.evalAst(printStmt.expression)
 	if err == nil {
@@ -94,11 +178,288 @@ func (interpreter *Interpreter) visitReturnStmt(returnStmt *ReturnStatement) {
 	if returnStmt.expression == nil {
 		interpreter.lastResult = NewNilValue()
 		interpreter.lastError = nil
+	} else if call := ungroup(returnStmt.expression); isCallExpr(call) && len(interpreter.deferred) == 0 &&
+		interpreter.generator == nil && interpreter.task == nil {
+		interpreter.returnCall(call)
 	} else {
 		interpreter.lastResult, interpreter.lastError = interpreter.evalAst(returnStmt.expression)
 	}
 
-	interpreter.returnOccurred = true
+	interpreter.controlFlow = controlFlow{kind: cfReturn}
+}
+
+// ungroup removes the parentheses around an expression, so that return (f(x));
+// is a tail call as well
+func ungroup(expr Expr) Expr {
+	for {
+		group, isGroup := expr.(*GroupExpr)
+		if !isGroup {
+			return expr
+		}
+		expr = group.Inner
+	}
+}
+
+// returnCall handles return f(x); Calls of functions in tail position are not
+// executed here but handed over to the caller, which runs them in place of the
+// returning function. This keeps the call depth constant.
+func (interpreter *Interpreter) returnCall(expr Expr) {
+	call, err := interpreter.prepareCall(expr)
+	if err != nil {
+		return
+	}
+	lambda, isLambda := call.fn.(*LambdaValue)
+	if isLambda && !lambda.isGenerator {
+		interpreter.tailCall = call
+		interpreter.lastResult = NewNilValue()
+		interpreter.lastError = nil
+		return
+	}
+	interpreter.lastResult, interpreter.lastError = interpreter.call(call.fn, call.args, call.named)
+}
+
+func (interpreter *Interpreter) visitYieldStmt(yieldStmt *YieldStatement) {
+	if interpreter.generator == nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = errors.New("yield is not allowed outside of a generator body")
+		return
+	}
+	value, err := interpreter.evalAst(yieldStmt.expression)
+	if err != nil {
+		return
+	}
+	err = interpreter.generator.yield(value)
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return
+	}
+	interpreter.lastResult = NewNilValue()
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitBreakStmt(b *BreakStatement) {
+	interpreter.controlFlow = controlFlow{kind: cfBreak, label: b.label}
+	interpreter.lastResult = NewNilValue()
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitContinueStmt(c *ContinueStatement) {
+	interpreter.controlFlow = controlFlow{kind: cfContinue, label: c.label}
+	interpreter.lastResult = NewNilValue()
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitDeferStmt(deferStmt *DeferStatement) {
+	// callee and arguments are evaluated when the call is deferred
+	call, err := interpreter.prepareCall(deferStmt.call)
+	if err != nil {
+		return
+	}
+
+	interpreter.deferred = append(interpreter.deferred, *call)
+	interpreter.lastResult = NewNilValue()
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitSelectStmt(selectStmt *SelectStatement) {
+	var cases []reflect.SelectCase
+	var channels []*ChannelValue
+	for _, selectCase := range selectStmt.cases {
+		value, err := interpreter.evalAst(selectCase.channel)
+		if err != nil {
+			return
+		}
+		channel, ok := value.(*ChannelValue)
+		if !ok {
+			interpreter.lastResult = nil
+			interpreter.lastError = fmt.Errorf("select expects a channel but got %s", value)
+			return
+		}
+		channels = append(channels, channel)
+
+		if selectCase.value == nil {
+			cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channel.ch)})
+			continue
+		}
+		var element Value
+		element, err = interpreter.evalAst(selectCase.value)
+		if err != nil {
+			return
+		}
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectSend,
+			Chan: reflect.ValueOf(channel.ch),
+			Send: reflect.ValueOf(&element).Elem(),
+		})
+	}
+
+	// like ChannelValue.send, sending cases hold the read lock of their channel
+	// and are aborted when the channel gets closed
+	numCases := len(cases)
+	var locked []*ChannelValue
+	for i, selectCase := range selectStmt.cases {
+		if selectCase.value == nil || slices.Contains(locked, channels[i]) {
+			continue
+		}
+		channels[i].mu.RLock()
+		locked = append(locked, channels[i])
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(channels[i].closing)})
+	}
+	for _, channel := range locked {
+		select {
+		case <-channel.closing:
+			// the channel might be closed already, sending would panic
+			for _, channel := range locked {
+				channel.mu.RUnlock()
+			}
+			interpreter.lastResult = nil
+			interpreter.lastError = errSendOnClosed
+			return
+		default:
+		}
+	}
+	var chosen int
+	var received reflect.Value
+	var ok bool
+	var err error
+	if selectStmt.defaultCase != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+		chosen, received, ok = reflect.Select(cases)
+	} else {
+		chosen, received, ok, err = interpreter.env.scheduler().wait(cases, errors.New("deadlock: no case of select can proceed"))
+	}
+	for _, channel := range locked {
+		channel.mu.RUnlock()
+	}
+
+	switch {
+	case err != nil:
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return
+	case chosen < numCases:
+		selectCase := selectStmt.cases[chosen]
+		caseEnv := NewEnvironment(interpreter.env)
+		if selectCase.variable != "" {
+			var value Value = NewNilValue()
+			if ok {
+				value = received.Interface().(Value)
+			}
+			caseEnv.Set(selectCase.variable, value)
+		}
+		interpreter.env = caseEnv
+		interpreter.visitBlock(&selectCase.body)
+		interpreter.env = caseEnv.parent
+	case cases[chosen].Dir == reflect.SelectDefault:
+		interpreter.visitBlock(selectStmt.defaultCase)
+	default:
+		interpreter.lastResult = nil
+		interpreter.lastError = errSendOnClosed
+		return
+	}
+
+	if interpreter.lastError == nil && !interpreter.interrupted() {
+		interpreter.lastResult = NewNilValue()
+	}
+}
+
+func (interpreter *Interpreter) visitAwaitExpr(awaitExpr *AwaitExpr) {
+	value, err := interpreter.evalAst(awaitExpr.value)
+	if err != nil {
+		return
+	}
+	if interpreter.task == nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = errors.New("await is only allowed in async functions")
+		return
+	}
+	interpreter.lastResult, interpreter.lastError = interpreter.task.await(value)
+}
+
+func (interpreter *Interpreter) visitSpawnExpr(spawnExpr *SpawnExpr) {
+	call, err := interpreter.prepareCall(spawnExpr.call)
+	if err != nil {
+		return
+	}
+	interpreter.lastResult = spawn(interpreter.env.scheduler(), call.fn, call.args, call.named)
+	interpreter.lastError = nil
+}
+
+// prepareCall evaluates the callee and the arguments of a function or method
+// call without calling it
+func (interpreter *Interpreter) prepareCall(expr Expr) (*deferredCall, error) {
+	var fn callable
+	var call *Call
+	var err error
+
+	switch expr := expr.(type) {
+	case *Call:
+		call = expr
+		var value Value
+		value, err = interpreter.evalAst(call.callee)
+		if err != nil {
+			return nil, err
+		}
+		var ok bool
+		fn, ok = value.(callable)
+		if !ok {
+			err = errors.New("invalid callable")
+		}
+	case *BinaryExpr:
+		call = expr.Right.(*Call)
+		var value Value
+		value, err = interpreter.evalAst(expr.Left)
+		if err != nil {
+			return nil, err
+		}
+		object, hasMembers := value.(memberAccessor)
+		if hasMembers {
+			fn, err = interpreter.evalMethod(object, call.callee)
+		} else {
+			err = fmt.Errorf("expected value with members but got %T", value)
+		}
+	}
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return nil, err
+	}
+
+	args, named, err := interpreter.evalCallArguments(call)
+	if err != nil {
+		return nil, err
+	}
+	return &deferredCall{fn, args, named}, nil
+}
+
+// call invokes fn from within the interpreter. Calls of functions and classes
+// count towards the maximum call depth.
+func (interpreter *Interpreter) call(fn callable, args []Value, named []namedArg) (Value, error) {
+	switch fn := fn.(type) {
+	case *LambdaValue:
+		return fn.invoke(interpreter.callDepth+1, args, named)
+	case *ClassValue:
+		return fn.instantiate(interpreter.callDepth+1, args, named)
+	default:
+		return callAt(fn, interpreter.callDepth+1, args, named)
+	}
+}
+
+// runDeferred executes the deferred calls in reverse order of their
+// registration. Errors of deferred calls are only reported if the function
+// body itself succeeded.
+func (interpreter *Interpreter) runDeferred() {
+	result, err := interpreter.lastResult, interpreter.lastError
+	for i := len(interpreter.deferred) - 1; i >= 0; i-- {
+		deferred := interpreter.deferred[i]
+		_, errDeferred := interpreter.call(deferred.fn, deferred.args, deferred.named)
+		if errDeferred != nil && err == nil {
+			result, err = nil, errDeferred
+		}
+	}
+	interpreter.deferred = nil
+	interpreter.lastResult, interpreter.lastError = result, err
 }
 
 func (interpreter *Interpreter) visitExprStmt(exprStmt *ExpressionStatement) {
@@ -141,9 +502,15 @@ func (interpreter *Interpreter) visitWhileStmt(whileStmt *WhileStatement) {
 		}
 
 		_, err = interpreter.evalAst(whileStmt.statement)
-		if err != nil || interpreter.returnOccurred {
+		if err != nil {
 			return
 		}
+		if interpreter.leaveLoop(whileStmt.label) {
+			break
+		}
+	}
+	if interpreter.interrupted() {
+		return // an outer loop or the function is left
 	}
 	interpreter.lastResult = NewNilValue()
 	interpreter.lastError = nil
@@ -178,9 +545,12 @@ func (interpreter *Interpreter) visitForStmt(forStmt *ForStatement) {
 		}
 
 		_, err = interpreter.evalAst(forStmt.statement)
-		if err != nil || interpreter.returnOccurred {
+		if err != nil {
 			return
 		}
+		if interpreter.leaveLoop(forStmt.label) {
+			break
+		}
 
 		if forStmt.increment != nil {
 			_, err = interpreter.evalAst(forStmt.increment)
@@ -190,10 +560,91 @@ func (interpreter *Interpreter) visitForStmt(forStmt *ForStatement) {
 		}
 	}
 
+	if interpreter.interrupted() {
+		return // an outer loop or the function is left
+	}
+	interpreter.lastResult = NewNilValue()
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitForInStmt(forInStmt *ForInStatement) {
+	value, err := interpreter.evalAst(forInStmt.iterable)
+	if err != nil {
+		return
+	}
+	iter, err := iterate(interpreter.callDepth+1, value)
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return
+	}
+
+	if closer, ok := iter.(iteratorCloser); ok {
+		defer closer.close()
+	}
+	outerEnv := interpreter.env
+	defer func() {
+		interpreter.env = outerEnv
+	}()
+
+	for {
+		hasNext, errNext := iter.hasNext()
+		if errNext != nil {
+			interpreter.lastResult = nil
+			interpreter.lastError = errNext
+			return
+		}
+		if !hasNext {
+			break
+		}
+		element, errNext := iter.next()
+		if errNext != nil {
+			interpreter.lastResult = nil
+			interpreter.lastError = errNext
+			return
+		}
+
+		// each iteration gets its own binding so that closures capture the current element
+		interpreter.env = NewEnvironment(outerEnv)
+		interpreter.env.Set(forInStmt.variable, element)
+
+		_, err = interpreter.evalAst(forInStmt.statement)
+		if err != nil {
+			return
+		}
+		if interpreter.leaveLoop(forInStmt.label) {
+			break
+		}
+	}
+
+	if interpreter.interrupted() {
+		return // an outer loop or the function is left
+	}
 	interpreter.lastResult = NewNilValue()
 	interpreter.lastError = nil
 }
 
+func (interpreter *Interpreter) interrupted() bool {
+	return interpreter.controlFlow.kind != cfNone
+}
+
+// leaveLoop is called after each iteration of the loop with the given label.
+// Break and continue signals targeting the loop are consumed. The return value
+// tells whether the loop has to be left.
+func (interpreter *Interpreter) leaveLoop(label string) bool {
+	signal := interpreter.controlFlow
+	switch signal.kind {
+	case cfNone:
+		return false
+	case cfBreak, cfContinue:
+		if signal.label == "" || signal.label == label {
+			interpreter.controlFlow = controlFlow{}
+			return signal.kind == cfBreak
+		}
+	}
+	return true
+}
+
 func (interpreter *Interpreter) visitClassDef(c *ClassDef) {
 	var methods []LambdaValue
 	var method Value
@@ -217,23 +668,55 @@ func (interpreter *Interpreter) visitClassDef(c *ClassDef) {
 	}
 
 	interpreter.env = NewEnvironment(interpreter.env)
+	defer func() {
+		interpreter.env = interpreter.env.parent
+	}()
+	// static members refer to the statics of the super class with super,
+	// methods bind super to the instance instead
+	if super != nil {
+		interpreter.env.Set("super", super)
+	}
 
+	statics := make(map[string]Value)
+	getters := make(map[string]*LambdaValue)
+	setters := make(map[string]*LambdaValue)
 	for _, function := range c.functions {
 		method, err = interpreter.evalAst(&function)
 		if err != nil {
 			break
 		}
-		methods = append(methods, *method.(*LambdaValue))
+		switch {
+		case function.isStatic:
+			statics[function.name] = method
+		case function.isGetter:
+			getters[function.name] = method.(*LambdaValue)
+		case function.isSetter:
+			setters[function.name] = method.(*LambdaValue)
+		default:
+			methods = append(methods, *method.(*LambdaValue))
+		}
+	}
+
+	if err != nil {
+		return
 	}
 
-	interpreter.env = interpreter.env.parent
+	class := NewClassValue(c.name, super, methods)
+	class.getters, class.setters, class.statics = getters, setters, statics
+	interpreter.env.parent.Set(c.name, class)
 
-	if err == nil {
-		class := NewClassValue(c.name, super, methods)
-		interpreter.env.Set(c.name, class)
-		interpreter.lastResult = class
-		interpreter.lastError = nil
+	// static fields are evaluated in the scope of the methods and can refer
+	// to the class
+	for _, field := range c.staticFields {
+		value, errField := interpreter.evalAst(field.expression)
+		if errField != nil {
+			return
+		}
+		_ = class.setProperty(field.name, value)
 	}
+
+	interpreter.lastResult = class
+	interpreter.lastError = nil
 }
 
 func (interpreter *Interpreter) visitFunctionDef(funDef *FunctionDef) {
@@ -243,11 +726,56 @@ func (interpreter *Interpreter) visitFunctionDef(funDef *FunctionDef) {
 		name = funDef.name
 	} else {
 		name = funDef.class.name + "::" + funDef.name
-		isConstructor = funDef.name == "init"
+		isConstructor = funDef.name == "init" && !funDef.isStatic && !funDef.isAccessor()
+	}
+	decorators, err := interpreter.evalDecorators(funDef.decorators)
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return
 	}
 	lambda := NewLambdaValue(name, funDef.parameters, funDef.body, *interpreter.env)
 	lambda.isConstructor = isConstructor
-	interpreter.env.Set(name, lambda)
+	lambda.isGenerator = funDef.isGenerator
+	lambda.isAsync = funDef.isAsync
+
+	// methods are decorated when they are bound to an instance
+	var value Value = lambda
+	if funDef.class == nil || funDef.isStatic {
+		value, err = decorate(interpreter.callDepth+1, lambda, decorators)
+		if err != nil {
+			interpreter.lastResult = nil
+			interpreter.lastError = err
+			return
+		}
+	} else {
+		lambda.decorators = decorators
+	}
+	interpreter.env.Set(name, value)
+	interpreter.lastResult = value
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) evalDecorators(exprs []Expr) ([]callable, error) {
+	var decorators []callable
+	for _, expr := range exprs {
+		value, err := interpreter.evalAst(expr)
+		if err != nil {
+			return nil, err
+		}
+		decorator, ok := value.(callable)
+		if !ok {
+			return nil, fmt.Errorf("decorator %s is not callable", value)
+		}
+		decorators = append(decorators, decorator)
+	}
+	return decorators, nil
+}
+
+func (interpreter *Interpreter) visitFunctionExpr(f *FunctionExpr) {
+	lambda := NewLambdaValue("lambda", f.function.parameters, f.function.body, *interpreter.env)
+	lambda.isGenerator = f.function.isGenerator
+	lambda.isAsync = f.function.isAsync
 	interpreter.lastResult = lambda
 	interpreter.lastError = nil
 }
@@ -257,6 +785,11 @@ func (interpreter *Interpreter) visitNumberExpr(numberExpr *NumberExpr) {
 	interpreter.lastError = nil
 }
 
+func (interpreter *Interpreter) visitIntegerExpr(integerExpr *IntegerExpr) {
+	interpreter.lastResult = NewBigIntValue(integerExpr.Value)
+	interpreter.lastError = nil
+}
+
 func (interpreter *Interpreter) visitBooleanExpr(booleanExpr *BooleanExpr) {
 	interpreter.lastResult = NewBooleanValue(booleanExpr.Value)
 	interpreter.lastError = nil
@@ -272,6 +805,19 @@ func (interpreter *Interpreter) visitStringExpr(stringExpr *StringExpr) {
 	interpreter.lastError = nil
 }
 
+func (interpreter *Interpreter) visitInterpolationExpr(interpolation *InterpolationExpr) {
+	var result strings.Builder
+	for _, part := range interpolation.parts {
+		value, err := interpreter.evalAst(part)
+		if err != nil {
+			return
+		}
+		result.WriteString(fmt.Sprint(value)) // same representation as print
+	}
+	interpreter.lastResult = NewStringValue(result.String())
+	interpreter.lastError = nil
+}
+
 func (interpreter *Interpreter) visitIdentifierExpr(identifierExpr *IdentifierExpr) {
 	var env *Environment
 	if identifierExpr.defLevel != -1 {
@@ -299,9 +845,8 @@ func (interpreter *Interpreter) visitUnaryExpr(unaryExpr *UnaryExpr) {
 	}
 
 	if unaryExpr.Operator.GetLexeme() == "-" {
-		if value.getType() == VtNumber {
-			num := value.(*NumValue)
-			interpreter.lastResult = NewNumValue(-num.Value)
+		if isNumber(value) {
+			interpreter.lastResult = negate(value)
 			interpreter.lastError = nil
 		} else {
 			interpreter.lastResult = nil
@@ -309,9 +854,8 @@ func (interpreter *Interpreter) visitUnaryExpr(unaryExpr *UnaryExpr) {
 		}
 	} else {
 		switch value.getType() {
-		case VtNumber:
-			num := value.(*NumValue)
-			interpreter.lastResult = NewBooleanValue(num.Value == 0)
+		case VtNumber, VtInteger:
+			interpreter.lastResult = NewBooleanValue(!value.isTruthy())
 			interpreter.lastError = nil
 		case VtString:
 			str := value.(*StringValue)
@@ -366,44 +910,38 @@ func (interpreter *Interpreter) visitBinaryExpr(expr *BinaryExpr) {
 
 	leftType := left.getType()
 	rightType := right.getType()
-	bothNums := leftType == VtNumber && rightType == VtNumber
+	bothNums := isNumber(left) && isNumber(right)
 
 	switch op {
-	case "*", "/", "-", ">", ">=", "<", "<=":
+	case "*", "/", "-":
 		if bothNums {
-			leftNum := left.(*NumValue).Value
-			rightNum := right.(*NumValue).Value
-			switch op {
-			case "*":
-				interpreter.lastResult = NewNumValue(leftNum * rightNum)
-			case "/":
-				interpreter.lastResult = NewNumValue(leftNum / rightNum)
-			case "-":
-				interpreter.lastResult = NewNumValue(leftNum - rightNum)
-			case ">":
-				interpreter.lastResult = NewBooleanValue(leftNum > rightNum)
-			case ">=":
-				interpreter.lastResult = NewBooleanValue(leftNum >= rightNum)
-			case "<":
-				interpreter.lastResult = NewBooleanValue(leftNum < rightNum)
-			case "<=":
-				interpreter.lastResult = NewBooleanValue(leftNum <= rightNum)
-			}
+			interpreter.lastResult = evalArithmetic(op, left, right)
+		} else {
+			interpreter.lastError = errors.New("only numbers are supported")
+		}
+	case ">", ">=", "<", "<=":
+		if bothNums {
+			interpreter.lastResult = NewBooleanValue(evalComparison(op, left, right))
 		} else {
 			interpreter.lastError = errors.New("only numbers are supported")
 		}
 	case "+":
 		if bothNums {
-			interpreter.lastResult = NewNumValue(left.(*NumValue).Value + right.(*NumValue).Value)
+			interpreter.lastResult = evalArithmetic(op, left, right)
 		} else if leftType == VtString && rightType == VtString {
 			interpreter.lastResult = NewStringValue(left.(*StringValue).Value + right.(*StringValue).Value)
+		} else if leftType == VtBytes && rightType == VtBytes {
+			interpreter.lastResult = left.(*BytesValue).concat(right.(*BytesValue))
 		} else {
 			interpreter.lastError = errors.New("only two numbers or two strings are supported")
 		}
-	case "==":
-		interpreter.lastResult = NewBooleanValue(left.isEqualTo(right))
-	case "!=":
-		interpreter.lastResult = NewBooleanValue(!left.isEqualTo(right))
+	case "==", "!=":
+		equal, errEqual := valuesEqual(interpreter.callDepth+1, left, right)
+		if errEqual != nil {
+			interpreter.lastError = errEqual
+		} else {
+			interpreter.lastResult = NewBooleanValue(equal == (op == "=="))
+		}
 	default:
 		interpreter.lastError = errors.New(fmt.Sprintf("unsupported operator %s", op))
 	}
@@ -415,12 +953,25 @@ func (interpreter *Interpreter) visitAssignment(assignment *Assignment) {
 	if err != nil {
 		return
 	}
+	interpreter.assign(assignment.left, assignment.defLevel, value)
+}
+
+// assign stores value in the target of an assignment. For identifiers defLevel
+// is the resolved scope level of the variable.
+func (interpreter *Interpreter) assign(left Expr, defLevel int, value Value) {
+	var err error
+
+	tuple, isTuple := left.(*TupleExpr)
+	if isTuple {
+		interpreter.assignTuple(tuple, value)
+		return
+	}
 
-	identifier, isIdent := assignment.left.(*IdentifierExpr)
+	identifier, isIdent := left.(*IdentifierExpr)
 	if isIdent {
 		var defEnv *Environment
-		if assignment.defLevel != -1 {
-			defEnv, err = interpreter.env.GetEnvAtLevel(assignment.defLevel)
+		if defLevel != -1 {
+			defEnv, err = interpreter.env.GetEnvAtLevel(defLevel)
 		} else {
 			defEnv, err = interpreter.env.GetDefiningEnv(identifier.name)
 		}
@@ -431,14 +982,40 @@ func (interpreter *Interpreter) visitAssignment(assignment *Assignment) {
 		return
 	}
 
-	pathExpr := assignment.left.(*BinaryExpr)
-	instance, property, err := interpreter.evalPathExprLhs(pathExpr)
+	indexExpr, isIndex := left.(*IndexExpr)
+	if isIndex {
+		container, errContainer := interpreter.evalAst(indexExpr.object)
+		if errContainer != nil {
+			return
+		}
+		interpreter.assignIndex(container, indexExpr.index, value)
+		return
+	}
+
+	pathExpr := left.(*BinaryExpr)
+	indexExpr, isIndex = pathExpr.Right.(*IndexExpr)
+	if isIndex {
+		container, errContainer := interpreter.evalPathExpr(NewBinaryExpr(pathExpr.Operator, pathExpr.Left, indexExpr.object))
+		if errContainer != nil {
+			interpreter.lastResult = nil
+			interpreter.lastError = errContainer
+			return
+		}
+		interpreter.assignIndex(container, indexExpr.index, value)
+		return
+	}
+
+	object, property, err := interpreter.evalPathExprLhs(pathExpr)
 	if err != nil {
 		interpreter.lastResult = nil
 		interpreter.lastError = err
 		return
 	}
-	err = instance.setProperty(property, value)
+	if instance, isInstance := object.(*InstanceValue); isInstance {
+		err = instance.setPropertyAt(interpreter.callDepth+1, property, value)
+	} else {
+		err = object.setProperty(property, value)
+	}
 	if err != nil {
 		interpreter.lastResult = nil
 		interpreter.lastError = err
@@ -449,6 +1026,54 @@ func (interpreter *Interpreter) visitAssignment(assignment *Assignment) {
 	interpreter.lastError = nil
 }
 
+// assignTuple destructures value into the elements of a tuple on the left hand
+// side of an assignment. Identifiers carry their own resolved scope level.
+func (interpreter *Interpreter) assignTuple(tuple *TupleExpr, value Value) {
+	values, err := unpack(interpreter.callDepth+1, value, len(tuple.elements))
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return
+	}
+	for i, element := range tuple.elements {
+		defLevel := -1
+		if identifier, isIdent := element.(*IdentifierExpr); isIdent {
+			defLevel = identifier.defLevel
+		}
+		interpreter.assign(element, defLevel, values[i])
+		if interpreter.lastError != nil {
+			return
+		}
+	}
+	interpreter.lastResult = value
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) assignIndex(container Value, indexExpr Expr, value Value) {
+	target, ok := container.(indexAssignable)
+	if !ok {
+		interpreter.lastResult = nil
+		interpreter.lastError = fmt.Errorf("index assignment is not supported for %s", container)
+		return
+	}
+	index, err := interpreter.evalAst(indexExpr)
+	if err != nil {
+		return
+	}
+	if m, isMap := target.(*MapValue); isMap {
+		err = m.setIndexAt(interpreter.callDepth+1, index, value)
+	} else {
+		err = target.setIndex(index, value)
+	}
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+		return
+	}
+	interpreter.lastResult = value
+	interpreter.lastError = nil
+}
+
 func (interpreter *Interpreter) visitCall(call *Call) {
 	value, err := interpreter.evalAst(call.callee)
 	if err != nil {
@@ -461,25 +1086,159 @@ func (interpreter *Interpreter) visitCall(call *Call) {
 		return
 	}
 
-	arguments, err := interpreter.evalArguments(call.args)
+	arguments, named, err := interpreter.evalCallArguments(call)
 	if err != nil {
 		return
 	}
 
-	interpreter.lastResult, interpreter.lastError = callableValue.call(arguments)
+	interpreter.lastResult, interpreter.lastError = interpreter.call(callableValue, arguments, named)
+}
+
+func (interpreter *Interpreter) visitSpreadExpr(*SpreadExpr) {
+	interpreter.lastResult = nil
+	interpreter.lastError = errors.New("spread is only allowed in argument lists")
+}
+
+// evalSpread evaluates the elements of a spread argument
+func (interpreter *Interpreter) evalSpread(spread *SpreadExpr) ([]Value, error) {
+	value, err := interpreter.evalAst(spread.inner)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := collect(interpreter.callDepth+1, value)
+	if err != nil {
+		interpreter.lastResult = nil
+		interpreter.lastError = err
+	}
+	return elements, err
+}
+
+func (interpreter *Interpreter) visitListExpr(list *ListExpr) {
+	var elements []Value
+	for _, element := range list.elements {
+		value, err := interpreter.evalAst(element)
+		if err != nil {
+			return
+		}
+		elements = append(elements, value)
+	}
+	interpreter.lastResult = NewListValue(elements)
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitTupleExpr(tuple *TupleExpr) {
+	var elements []Value
+	for _, element := range tuple.elements {
+		value, err := interpreter.evalAst(element)
+		if err != nil {
+			return
+		}
+		elements = append(elements, value)
+	}
+	interpreter.lastResult = NewTupleValue(elements)
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitMapExpr(mapExpr *MapExpr) {
+	ret := NewMapValue()
+	for i, keyExpr := range mapExpr.keys {
+		key, err := interpreter.evalAst(keyExpr)
+		if err != nil {
+			return
+		}
+		value, err := interpreter.evalAst(mapExpr.values[i])
+		if err != nil {
+			return
+		}
+		err = ret.setIndexAt(interpreter.callDepth+1, key, value)
+		if err != nil {
+			interpreter.lastResult = nil
+			interpreter.lastError = err
+			return
+		}
+	}
+	interpreter.lastResult = ret
+	interpreter.lastError = nil
+}
+
+func (interpreter *Interpreter) visitIndexExpr(indexExpr *IndexExpr) {
+	object, err := interpreter.evalAst(indexExpr.object)
+	if err != nil {
+		return
+	}
+	interpreter.lastResult, interpreter.lastError = interpreter.evalIndex(object, indexExpr.index)
 }
 
-func (interpreter *Interpreter) evalArguments(args []Expr) ([]Value, error) {
+func (interpreter *Interpreter) visitSliceExpr(sliceExpr *SliceExpr) {
+	object, err := interpreter.evalAst(sliceExpr.object)
+	if err != nil {
+		return
+	}
+	interpreter.lastResult, interpreter.lastError = interpreter.evalSlice(object, sliceExpr)
+}
+
+func (interpreter *Interpreter) evalIndex(object Value, indexExpr Expr) (Value, error) {
+	container, ok := object.(indexable)
+	if !ok {
+		return nil, fmt.Errorf("indexing is not supported for %s", object)
+	}
+	index, err := interpreter.evalAst(indexExpr)
+	if err != nil {
+		return nil, err
+	}
+	if m, isMap := container.(*MapValue); isMap {
+		return m.getIndexAt(interpreter.callDepth+1, index)
+	}
+	return container.getIndex(index)
+}
+
+func (interpreter *Interpreter) evalSlice(object Value, sliceExpr *SliceExpr) (Value, error) {
+	container, ok := object.(sliceable)
+	if !ok {
+		return nil, fmt.Errorf("slicing is not supported for %s", object)
+	}
+	var bounds []Value
+	for _, bound := range []Expr{sliceExpr.start, sliceExpr.end} {
+		if bound == nil {
+			bounds = append(bounds, nil)
+			continue
+		}
+		value, err := interpreter.evalAst(bound)
+		if err != nil {
+			return nil, err
+		}
+		bounds = append(bounds, value)
+	}
+	return container.slice(bounds[0], bounds[1])
+}
+
+// evalCallArguments evaluates the positional and named arguments of a call
+func (interpreter *Interpreter) evalCallArguments(call *Call) ([]Value, []namedArg, error) {
 	var arguments []Value
+	var named []namedArg
+
+	for i, arg := range call.args {
+		spread, isSpread := arg.(*SpreadExpr)
+		if isSpread {
+			elements, err := interpreter.evalSpread(spread)
+			if err != nil {
+				return nil, nil, err
+			}
+			arguments = append(arguments, elements...)
+			continue
+		}
 
-	for _, arg := range args {
 		argument, err := interpreter.evalAst(arg)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
+		}
+		if call.argNames[i] != "" {
+			named = append(named, namedArg{call.argNames[i], argument})
+		} else {
+			arguments = append(arguments, argument)
 		}
-		arguments = append(arguments, argument)
 	}
-	return arguments, nil
+	return arguments, named, nil
 }
 
 func (interpreter *Interpreter) evalDisjunction(expr *BinaryExpr) (Value, error) {
@@ -512,35 +1271,35 @@ func (interpreter *Interpreter) evalConjunction(expr *BinaryExpr) (Value, error)
 	return right, nil
 }
 
-func (interpreter *Interpreter) evalPathExprLhs(expr *BinaryExpr) (*InstanceValue, string, error) {
+func (interpreter *Interpreter) evalPathExprLhs(expr *BinaryExpr) (propertySetter, string, error) {
 	value, err := interpreter.evalAst(expr.Left)
 	if err != nil {
 		return nil, "", err
 	}
-	instance, isInstance := value.(*InstanceValue)
-	if !isInstance {
-		return nil, "", fmt.Errorf("expected instance but got %T", value)
+	object, isSetter := value.(propertySetter)
+	if !isSetter {
+		return nil, "", fmt.Errorf("expected instance or class but got %T", value)
 	}
-	return interpreter.evalPathLhs(instance, expr.Right)
+	return interpreter.evalPathLhs(object, expr.Right)
 }
 
-func (interpreter *Interpreter) evalPathLhs(instance *InstanceValue, expr Expr) (*InstanceValue, string, error) {
+func (interpreter *Interpreter) evalPathLhs(object propertySetter, expr Expr) (propertySetter, string, error) {
 	ident, isIdent := expr.(*IdentifierExpr)
 	if isIdent {
-		return instance, ident.name, nil
+		return object, ident.name, nil
 	}
 
 	binExpr, isBinExpr := expr.(*BinaryExpr)
 	if isBinExpr {
-		next, err := interpreter.evalPath(instance, binExpr.Left)
+		next, err := interpreter.evalPath(object, binExpr.Left)
 		if err != nil {
 			return nil, "", err
 		}
-		nextInstance, isInstance := next.(*InstanceValue)
-		if !isInstance {
-			return nil, "", errors.New("expected expression to evaluate to an instance")
+		nextObject, isSetter := next.(propertySetter)
+		if !isSetter {
+			return nil, "", errors.New("expected expression to evaluate to an instance or class")
 		}
-		return interpreter.evalPathLhs(nextInstance, binExpr.Right)
+		return interpreter.evalPathLhs(nextObject, binExpr.Right)
 	}
 
 	return nil, "", errors.New("invalid expression as lhs")
@@ -551,53 +1310,80 @@ func (interpreter *Interpreter) evalPathExpr(expr *BinaryExpr) (Value, error) {
 	if err != nil {
 		return nil, err
 	}
-	instance, isInstance := value.(*InstanceValue)
-	if !isInstance {
-		return nil, fmt.Errorf("expected instance but got %T", value)
+	object, hasMembers := value.(memberAccessor)
+	if !hasMembers {
+		return nil, fmt.Errorf("expected value with members but got %T", value)
 	}
-	return interpreter.evalPath(instance, expr.Right)
+	return interpreter.evalPath(object, expr.Right)
 }
 
-func (interpreter *Interpreter) evalPath(instance *InstanceValue, expr Expr) (Value, error) {
+func (interpreter *Interpreter) evalPath(object memberAccessor, expr Expr) (Value, error) {
 	ident, isIdent := expr.(*IdentifierExpr)
 	if isIdent {
-		return instance.getMember(ident.name)
+		return interpreter.getMember(object, ident.name)
 	}
 
 	call, isCall := expr.(*Call)
 	if isCall {
-		method, errMethod := interpreter.evalMethod(instance, call.callee)
+		method, errMethod := interpreter.evalMethod(object, call.callee)
 		if errMethod != nil {
 			return nil, errMethod
 		}
 
-		arguments, errArgs := interpreter.evalArguments(call.args)
+		arguments, named, errArgs := interpreter.evalCallArguments(call)
 		if errArgs != nil {
 			return nil, errArgs
 		}
-		return method.call(arguments)
+		return interpreter.call(method, arguments, named)
+	}
+
+	indexExpr, isIndex := expr.(*IndexExpr)
+	if isIndex {
+		container, err := interpreter.evalPath(object, indexExpr.object)
+		if err != nil {
+			return nil, err
+		}
+		return interpreter.evalIndex(container, indexExpr.index)
+	}
+
+	sliceExpr, isSlice := expr.(*SliceExpr)
+	if isSlice {
+		container, err := interpreter.evalPath(object, sliceExpr.object)
+		if err != nil {
+			return nil, err
+		}
+		return interpreter.evalSlice(container, sliceExpr)
 	}
 
 	binExpr, isBinExpr := expr.(*BinaryExpr)
 	if isBinExpr {
-		next, err := interpreter.evalPath(instance, binExpr.Left)
+		next, err := interpreter.evalPath(object, binExpr.Left)
 		if err != nil {
 			return nil, err
 		}
-		nextInstance, isInstance := next.(*InstanceValue)
-		if !isInstance {
-			return nil, errors.New("expected expression to evaluate to an instance")
+		nextObject, hasMembers := next.(memberAccessor)
+		if !hasMembers {
+			return nil, errors.New("expected expression to evaluate to a value with members")
 		}
-		return interpreter.evalPath(nextInstance, binExpr.Right)
+		return interpreter.evalPath(nextObject, binExpr.Right)
 	}
 
 	return nil, errors.New("invalid path segment")
 }
 
-func (interpreter *Interpreter) evalMethod(instance *InstanceValue, callee Expr) (callable, error) {
+// getMember gets a member of an object. Getters of instances count towards
+// the call depth of the interpreter.
+func (interpreter *Interpreter) getMember(object memberAccessor, name string) (Value, error) {
+	if instance, isInstance := object.(*InstanceValue); isInstance {
+		return instance.getMemberAt(interpreter.callDepth+1, name)
+	}
+	return object.getMember(name)
+}
+
+func (interpreter *Interpreter) evalMethod(object memberAccessor, callee Expr) (callable, error) {
 	ident, isIdent := callee.(*IdentifierExpr)
 	if isIdent {
-		member, errMember := instance.getMember(ident.name)
+		member, errMember := interpreter.getMember(object, ident.name)
 		if errMember != nil {
 			return nil, errMember
 		}
@@ -610,15 +1396,15 @@ func (interpreter *Interpreter) evalMethod(instance *InstanceValue, callee Expr)
 
 	call, isCall := callee.(*Call)
 	if isCall {
-		calleeValue, errCallee := interpreter.evalMethod(instance, call.callee)
+		calleeValue, errCallee := interpreter.evalMethod(object, call.callee)
 		if errCallee != nil {
 			return nil, errCallee
 		}
-		arguments, errArgs := interpreter.evalArguments(call.args)
+		arguments, named, errArgs := interpreter.evalCallArguments(call)
 		if errArgs != nil {
 			return nil, errArgs
 		}
-		value, errCall := calleeValue.call(arguments)
+		value, errCall := interpreter.call(calleeValue, arguments, named)
 		if errCall != nil {
 			return nil, errCall
 		}
@@ -629,6 +1415,19 @@ func (interpreter *Interpreter) evalMethod(instance *InstanceValue, callee Expr)
 		return method, nil
 	}
 
+	_, isIndex := callee.(*IndexExpr)
+	if isIndex {
+		value, err := interpreter.evalPath(object, callee)
+		if err != nil {
+			return nil, err
+		}
+		method, isMethod := value.(callable)
+		if !isMethod {
+			return nil, errors.New(fmt.Sprintf("expected callable but got %T", value))
+		}
+		return method, nil
+	}
+
 	return nil, fmt.Errorf("invalid callee type: %T", callee)
 }
 
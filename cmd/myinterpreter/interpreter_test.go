@@ -1,7 +1,12 @@
 package main
 
 import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestInterpreter_Eval(t *testing.T) {
@@ -307,3 +312,1291 @@ func TestInterpreter_ClassWithSuperClass(t *testing.T) {
 		t.Fatalf("interpreter.Run() error = %v", err)
 	}
 }
+
+func TestInterpreter_LabeledLoops(t *testing.T) {
+	code := `
+		var found = nil;
+		outer: for (var i = 0; i < 5; i = i + 1) {
+			for (var j = 0; j < 5; j = j + 1) {
+				if (j > i) continue outer;
+				if (i * j == 6) {
+					found = i * 10 + j;
+					break outer;
+				}
+			}
+		}`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	found, _ := interpreter.env.Get("found")
+	if !found.isEqualTo(NewNumValue(32)) {
+		t.Fatalf("Expected 32, got %s", found)
+	}
+}
+
+func TestInterpreter_BreakWithUnknownLabel(t *testing.T) {
+	code := `
+		inner: while (true) {}
+		outer: while (true) {
+			break inner;
+		}`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err == nil {
+		t.Fatalf("expected interpreter error did not occur")
+	}
+}
+
+func TestInterpreter_ForIn(t *testing.T) {
+	code := `
+		class Countdown {
+			init(n) { this.n = n; }
+			iterator() { return CountdownIterator(this.n); }
+		}
+		class CountdownIterator {
+			init(n) { this.n = n; }
+			hasNext() { return this.n > 0; }
+			next() {
+				this.n = this.n - 1;
+				return this.n + 1;
+			}
+		}
+		var sum = 0;
+		for (var i in Countdown(4)) sum = sum + i;
+		for (var i in range(0, 10, 5)) sum = sum + i;
+		var text = "";
+		for (var c in "abc") text = c + text;`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	sum, _ := interpreter.env.Get("sum")
+	if !sum.isEqualTo(NewNumValue(15)) {
+		t.Fatalf("Expected 15, got %s", sum)
+	}
+	text, _ := interpreter.env.Get("text")
+	if !text.isEqualTo(NewStringValue("cba")) {
+		t.Fatalf("Expected cba, got %s", text)
+	}
+}
+
+func TestInterpreter_Generator(t *testing.T) {
+	code := `
+		fun* countTo(n) {
+			var i = 0;
+			while (true) {
+				{
+					i = i + 1;
+					if (i > n) return;
+					yield i;
+				}
+			}
+		}
+		var gen = countTo(2);
+		var first = gen.next();
+		var sum = 0;
+		for (var i in countTo(4)) sum = sum + i;
+		var second = gen.next();
+		var done = gen.done;`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"first":  NewNumValue(1),
+		"second": NewNumValue(2),
+		"sum":    NewNumValue(10),
+		"done":   NewBooleanValue(true),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_Defer(t *testing.T) {
+	code := `
+		var trace = "";
+		fun log(msg) { trace = trace + msg; }
+		fun work(fail) {
+			defer log("1");
+			defer log("2");
+			if (fail) return undefinedFunction();
+			log("body");
+			return "ok";
+		}
+		var result = work(false);
+		work(true);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err == nil {
+		t.Fatalf("expected interpreter error did not occur")
+	}
+	result, _ := interpreter.env.Get("result")
+	if !result.isEqualTo(NewStringValue("ok")) {
+		t.Fatalf("Expected ok, got %s", result)
+	}
+	trace, _ := interpreter.env.Get("trace")
+	if !trace.isEqualTo(NewStringValue("body2121")) {
+		t.Fatalf("Expected body2121, got %s", trace)
+	}
+}
+
+func TestInterpreter_List(t *testing.T) {
+	code := `
+		var xs = [3, 1, 2];
+		xs.push(5);
+		xs[0] = xs[-1] * 2;
+		xs.sort();
+		var sorted = xs;
+		var middle = xs[1:3];
+		var size = len(xs);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"sorted": NewListValue([]Value{NewNumValue(1), NewNumValue(2), NewNumValue(5), NewNumValue(10)}),
+		"middle": NewListValue([]Value{NewNumValue(2), NewNumValue(5)}),
+		"size":   NewNumValue(4),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_ListIndexOutOfRange(t *testing.T) {
+	code := `
+		var xs = [1, 2, 3];
+		print xs[-4];`
+
+	interpreter := NewInterpreter(nil)
+	err, isRuntimeError := interpreter.Run(code)
+	if err == nil || !isRuntimeError {
+		t.Fatalf("expected runtime error did not occur")
+	}
+}
+
+func TestInterpreter_Map(t *testing.T) {
+	code := `
+		class Point {
+			init(x, y) { this.x = x; this.y = y; }
+			hash() { return this.x * 1000 + this.y; }
+			equals(other) { return this.x == other.x and this.y == other.y; }
+		}
+		var m = {"b": 2, "a": 1};
+		m["c"] = 3;
+		m.remove("b");
+		m[Point(1, 2)] = "p";
+		m[Point(1, 2)] = "q";
+		var keys = "";
+		for (var key in m.keys()) if (key != Point(1, 2)) keys = keys + key;
+		var size = len(m);
+		var point = m[Point(1, 2)];`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"keys":  NewStringValue("ac"),
+		"size":  NewNumValue(3),
+		"point": NewStringValue("q"),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_MapKeyCollisions(t *testing.T) {
+	code := `
+		class P {
+			init(x, y) { this.x = x; this.y = y; }
+			hash() { return this.x * 1000 + this.y; }
+		}
+		class Q < P {
+			equals(other) { return this.x == other.x and this.y == other.y; }
+		}
+		var byIdentity = {};
+		var p = P(0, 1000);
+		byIdentity[p] = 1;
+		byIdentity[P(1, 0)] = 2;
+		byIdentity[p] = 3;
+		var identityKeys = len(byIdentity);
+		var identityValue = byIdentity[p];
+		var byEquals = {};
+		byEquals[Q(0, 1000)] = 1;
+		byEquals[Q(1, 0)] = 2;
+		byEquals[Q(0, 1000)] = 3;
+		var equalsKeys = len(byEquals);
+		var equalsValue = byEquals[Q(0, 1000)];`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"identityKeys":  NewIntValue(2),
+		"identityValue": NewIntValue(3),
+		"equalsKeys":    NewIntValue(2),
+		"equalsValue":   NewIntValue(3),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_StringInterpolation(t *testing.T) {
+	code := `
+		class User {
+			init(name) { this.name = name; }
+		}
+		fun greet(user, count) {
+			return "Hello ${user.name}, you have ${count + 1} messages ${[count]}";
+		}
+		var greeting = greet(User("Ann"), 2);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	greeting, _ := interpreter.env.Get("greeting")
+	expected := NewStringValue("Hello Ann, you have 3 messages [2]")
+	if !greeting.isEqualTo(expected) {
+		t.Fatalf("Expected %s, got %s", expected, greeting)
+	}
+}
+
+func TestInterpreter_StringMethods(t *testing.T) {
+	code := `
+		var s = "héllo wörld";
+		var length = s.length;
+		var position = s.indexOf("wö");
+		var word = s.substring(6).upper();
+		var parts = "a,b".split(",");
+		var char = s.charAt(-4);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"length":   NewNumValue(11),
+		"position": NewNumValue(6),
+		"word":     NewStringValue("WÖRLD"),
+		"parts":    NewListValue([]Value{NewStringValue("a"), NewStringValue("b")}),
+		"char":     NewStringValue("ö"),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_Integers(t *testing.T) {
+	code := `
+		var exact = 9007199254740993 + 2;
+		var promoted = 9223372036854775807 + 1;
+		var quotient = 6 / 3;
+		var fraction = 7 / 2;
+		var parsed = int("123456789012345678901") * 10;
+		var mixed = 1 + 0.5;`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"exact":    "9007199254740995",
+		"promoted": "9223372036854775808",
+		"quotient": "2",
+		"fraction": "3.5",
+		"parsed":   "1234567890123456789010",
+		"mixed":    "1.5",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+	quotient, _ := interpreter.env.Get("quotient")
+	if quotient.getType() != VtInteger {
+		t.Fatalf("Expected 6 / 3 to be an integer")
+	}
+}
+
+func TestInterpreter_NumberPrinting(t *testing.T) {
+	for value, expected := range map[float64]string{
+		1e21:         "1e+21",
+		1e20:         "100000000000000000000",
+		0.0000001:    "0.0000001",
+		0.00000001:   "1e-8",
+		1.0 / 3:      "0.3333333333333333",
+		math.Inf(-1): "-Infinity",
+	} {
+		if NewNumValue(value).String() != expected {
+			t.Fatalf("Expected %v to print as %s, got %s", value, expected, NewNumValue(value))
+		}
+	}
+}
+
+func TestInterpreter_NumberConstants(t *testing.T) {
+	code := `
+		var nan = NaN;
+		var negative = -Infinity;
+		var unequal = NaN == NaN;
+		class Range { init() { this.Infinity = "field"; } }
+		var field = Range().Infinity;
+		fun shadow(NaN) { return NaN; }
+		var param = shadow(1);
+		var NaN = "global";`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"nan":      "NaN",
+		"negative": "-Infinity",
+		"unequal":  "false",
+		"field":    "field",
+		"param":    "1",
+		"NaN":      "global",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_TupleDestructuring(t *testing.T) {
+	code := `
+		fun divide(a, b) { return (a / b, a - b * int(a / b)); }
+		var (quotient, remainder) = divide(7, 2);
+		var a = 1;
+		var b = 2;
+		(a, b) = (b, a);
+		var (x, (y, z)) = (1, ["two", 3]);
+		var key = {(1, 2): "pair"}[(1, 2)];`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"quotient":  NewNumValue(3.5),
+		"remainder": NewNumValue(1),
+		"a":         NewNumValue(2),
+		"b":         NewNumValue(1),
+		"y":         NewStringValue("two"),
+		"z":         NewNumValue(3),
+		"key":       NewStringValue("pair"),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_DestructuringCountMismatch(t *testing.T) {
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run("var (x, y) = (1, 2, 3);")
+	if err == nil {
+		t.Fatalf("Expected destructuring error")
+	}
+}
+
+func TestInterpreter_Set(t *testing.T) {
+	code := `
+		class Record {
+			init(id) { this.id = id; }
+			hash() { return this.id; }
+			equals(other) { return this.id == other.id; }
+		}
+		var records = set([Record(1), Record(2), Record(1)]);
+		var count = len(records);
+		var a = set([1, 2, 3]);
+		a.add(2.0, 4);
+		var union = a.union([5]);
+		var common = a.intersection(set([2, 4, 6]));
+		var rest = a.difference([1, 2]);
+		var subset = common.isSubset(a);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"count":  "2",
+		"a":      "{1, 2, 3, 4}",
+		"union":  "{1, 2, 3, 4, 5}",
+		"common": "{2, 4}",
+		"rest":   "{3, 4}",
+		"subset": "true",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_SetInstanceKeys(t *testing.T) {
+	code := `
+		class Cell {
+			init(id) { this.id = id; }
+			hash() { return 0; }
+		}
+		var a = Cell(1);
+		var cells = set([a, Cell(2), a]);
+		var pairs = set([(a, 1), (Cell(2), 1), (a, 1)]);
+		var index = {};
+		index[(a, "x")] = 1;
+		index[(Cell(1), "x")] = 2;
+		var count = len(cells);
+		var pairCount = len(pairs);
+		var indexCount = len(index);
+		var hasA = cells.has(a);
+		var hasOther = cells.has(Cell(1));`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"count":      "2",
+		"pairCount":  "2",
+		"indexCount": "2",
+		"hasA":       "true",
+		"hasOther":   "false",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_EqualityMatchesMembership(t *testing.T) {
+	code := `
+		class A { hash() { return 0; } }
+		class Point {
+			init(x, y) { this.x = x; this.y = y; }
+			hash() { return this.x; }
+			equals(other) { return this.x == other.x and this.y == other.y; }
+		}
+		var a = A();
+		var p = Point(1, 2);
+		var results = [
+			a == A(), set([a]).has(A()),
+			a == a, set([a]).has(a),
+			p == Point(1, 2), set([p]).has(Point(1, 2)),
+			p == Point(1, 3), set([p]).has(Point(1, 3)),
+			(p, 1) == (Point(1, 2), 1), set([(p, 1)]).has((Point(1, 2), 1)),
+			p != Point(1, 2)
+		];
+		var count = len(set([A(), A()]));`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"results": "[false, false, true, true, true, true, false, false, true, true, false]",
+		"count":   "2",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_Bytes(t *testing.T) {
+	code := `
+		var header = bytes("GIF89a", "ascii") + bytes("0100ff", "hex");
+		var size = len(header);
+		var last = header[-1];
+		var magic = header[0:3].decode();
+		var encoded = header.base64();
+		var roundTrip = bytes(encoded, "base64") == header;`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"header":    `b"GIF89a\x01\x00\xff"`,
+		"size":      "9",
+		"last":      "255",
+		"magic":     "GIF",
+		"encoded":   "R0lGODlhAQD/",
+		"roundTrip": "true",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_AnonymousFunctions(t *testing.T) {
+	code := `
+		fun makeAdder(n) { return (x) => x + n; }
+		var sum = fun (a, b) { return a + b; }(1, 2);
+		var added = makeAdder(10)(5);
+		var xs = [3, 1, 2];
+		xs.sort((a, b) => b - a);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"sum":   NewNumValue(3),
+		"added": NewNumValue(15),
+		"xs":    NewListValue([]Value{NewNumValue(3), NewNumValue(2), NewNumValue(1)}),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_DefaultAndNamedArguments(t *testing.T) {
+	code := `
+		var defaultPort = 8080;
+		fun connect(host, port = defaultPort, secure = false) {
+			return "${host}:${port}:${secure}";
+		}
+		defaultPort = 80;
+		var plain = connect("db");
+		var secure = connect("db", secure = true);
+		var all = connect(port = 1, host = "x", secure = nil);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"plain":  NewStringValue("db:80:false"),
+		"secure": NewStringValue("db:80:true"),
+		"all":    NewStringValue("x:1:nil"),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_NamedArgumentErrors(t *testing.T) {
+	for _, code := range []string{
+		"fun f(a) {} f(b = 1);",
+		"fun f(a) {} f(1, a = 2);",
+		"fun f(a) {} f(a = 1, a = 2);",
+	} {
+		interpreter := NewInterpreter(nil)
+		err, _ := interpreter.Run(code)
+		if err == nil {
+			t.Fatalf("Expected error for %s", code)
+		}
+	}
+}
+
+func TestInterpreter_RestParametersAndSpread(t *testing.T) {
+	code := `
+		fun join(separator, ...parts) {
+			var ret = "";
+			for (var part in parts) {
+				if (ret != "") ret = ret + separator;
+				ret = ret + part;
+			}
+			return ret;
+		}
+		var words = ["a", "b", "c"];
+		var joined = join("-", ...words, "d");
+		var empty = join(",");
+		var largest = max(...[3, 9, 4], 7);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"joined":  NewStringValue("a-b-c-d"),
+		"empty":   NewStringValue(""),
+		"largest": NewNumValue(9),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_MaxCallDepth(t *testing.T) {
+	defer SetMaxCallDepth(maxCallDepth)
+	SetMaxCallDepth(100)
+
+	interpreter := NewInterpreter(nil)
+	err, isRuntimeError := interpreter.Run("fun f(n) { return 1 + f(n + 1); } f(0);")
+	if err == nil || !isRuntimeError || !strings.Contains(err.Error(), "stack overflow") {
+		t.Fatalf("Expected stack overflow error, got %v", err)
+	}
+}
+
+func TestInterpreter_IndirectCallDepth(t *testing.T) {
+	defer SetMaxCallDepth(maxCallDepth)
+	SetMaxCallDepth(100)
+
+	for _, code := range []string{
+		"@memoize fun f(n) { if (n == 0) return 0; return 1 + f(n - 1); } print f(3000000);",
+		"fun* r(n) { for (var x in r(n + 1)) yield x; } for (var x in r(0)) print x;",
+		"fun f(n, x = f(n + 1)) { return x; } f(0);",
+		"fun cmp(a, b) { [2, 1].sort(cmp); return a - b; } [2, 1].sort(cmp);",
+		"class K { hash() { var m = {}; m[K()] = 1; return 1; } } var s = set([K()]);",
+		"class It { iterator() { for (var x in It()) {} return this; } } for (var x in It()) {}",
+		"fun deco(fn) { class A { @deco m() {} } A().m(); return fn; } class B { @deco m() {} } B().m();",
+	} {
+		interpreter := NewInterpreter(nil)
+		err, isRuntimeError := interpreter.Run(code)
+		if err == nil || !isRuntimeError || !strings.Contains(err.Error(), "stack overflow") {
+			t.Fatalf("Expected stack overflow error for %s, got %v", code, err)
+		}
+	}
+}
+
+func TestInterpreter_TailCalls(t *testing.T) {
+	defer SetMaxCallDepth(maxCallDepth)
+	SetMaxCallDepth(100)
+
+	code := `
+		fun isEven(n) { if (n == 0) return true; return isOdd(n - 1); }
+		fun isOdd(n) { if (n == 0) return false; return isEven(n - 1); }
+		fun countdown(n) { if (n == 0) return "done"; return ((countdown(n - 1))); }
+		class Counter {
+			count(n, acc) { if (n == 0) return acc; return this.count(n - 1, acc + 1); }
+		}
+		var even = isEven(10001);
+		var counted = Counter().count(5000, 0);
+		var grouped = countdown(5000);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"even":    NewBooleanValue(false),
+		"counted": NewIntValue(5000),
+		"grouped": NewStringValue("done"),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_Introspection(t *testing.T) {
+	code := `
+		fun add(a, b = 1, ...rest) { return a + b; }
+		class Point { init(x, y) {} }
+		class Point3 < Point {}
+		var addArity = arity(add);
+		var addName = nameOf(add);
+		var addParams = params(add);
+		var classArity = arity(Point3);
+		var classParams = params(Point3);
+		var builtinArity = arity(len);
+		var builtinName = nameOf(max);
+		var methodArities = [arity([].push), arity([].pop), arity("".replace), arity(set().has), arity(bytes("").decode)];
+		var callables = [isCallable(add), isCallable(Point), isCallable(clock), isCallable(1)];`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"addArity":      "1",
+		"addName":       "add",
+		"addParams":     `["a", "b", "...rest"]`,
+		"classArity":    "2",
+		"classParams":   `["x", "y"]`,
+		"builtinArity":  "1",
+		"builtinName":   "max",
+		"methodArities": "[1, 0, 2, 1, 0]",
+		"callables":     "[true, true, true, false]",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_BuiltinArity(t *testing.T) {
+	for code, expected := range map[string]string{
+		"clock(1);":                          "clock() expects 0 arguments but got 1",
+		"len();":                             "len() expects 1 argument but got 0",
+		"range();":                           "range() expects 1 to 3 arguments but got 0",
+		"max();":                             "max() expects at least 1 argument but got 0",
+		"bytes(1,2,3);":                      "bytes() expects 1 to 2 arguments but got 3",
+		"[].push();":                         "push() expects at least 1 argument but got 0",
+		"[1].pop(0, 1);":                     "pop() expects 0 to 1 arguments but got 2",
+		"\"a\".upper(1);":                    "upper() expects 0 arguments but got 1",
+		"set().union();":                     "union() expects 1 argument but got 0",
+		"var m = {}; m.keys(1);":             "keys() expects 0 arguments but got 1",
+		"bytes(\"a\").decode(\"utf-8\", 1);": "decode() expects 0 to 1 arguments but got 2",
+	} {
+		interpreter := NewInterpreter(nil)
+		err, _ := interpreter.Run(code)
+		if err == nil || err.Error() != expected {
+			t.Fatalf("Expected error %q for %s, got %v", expected, code, err)
+		}
+	}
+}
+
+func TestInterpreter_Decorators(t *testing.T) {
+	code := `
+		var calls = 0;
+		@memoize
+		fun fib(n) { calls = calls + 1; if (n < 2) return n; return fib(n - 1) + fib(n - 2); }
+		var fib50 = fib(50);
+
+		var log = [];
+		fun logged(f) {
+			return fun (...args) { log.push(nameOf(f)); return f(...args); };
+		}
+		fun twice(f) { return (x) => f(f(x)); }
+		@logged
+		@twice
+		fun inc(x) { return x + 1; }
+		var three = inc(1);
+
+		class Square {
+			init(side) { this.side = side; }
+			@logged
+			area() { return this.side * this.side; }
+		}
+		var area = Square(3).area();`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"fib50": "12586269025",
+		"calls": "51",
+		"three": "3",
+		"area":  "9",
+		"log":   `["lambda", "area"]`,
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_SpawnAndChannels(t *testing.T) {
+	code := `
+		fun sum(n) { var s = 0; for (var i in range(n + 1)) s = s + i; return s; }
+		var tasks = [];
+		for (var n in [10, 100, 1000]) tasks.push(spawn sum(n));
+		var sums = [];
+		for (var task in tasks) sums.push(task.join());
+
+		var squares = channel(8);
+		var wg = waitGroup();
+		var seen = set();
+		for (var i in range(8)) {
+			wg.add();
+			spawn (fun (k) { seen.add(k); squares.send(k * k); wg.done(); })(i);
+		}
+		wg.wait();
+		squares.close();
+		var total = 0;
+		for (var square in squares) total = total + square;
+
+		var pings = channel();
+		spawn pings.send("ping");
+		var received;
+		select {
+			case var msg = pings.recv() { received = msg; }
+		}
+		var idle = channel();
+		var fallback = false;
+		select {
+			case idle.recv() { fallback = nil; }
+			default { fallback = true; }
+		}`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"sums":     "[55, 5050, 500500]",
+		"total":    "140",
+		"seen":     "8",
+		"received": "ping",
+		"fallback": "true",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if name == "seen" {
+			value = NewIntValue(int64(value.(*SetValue).length()))
+		}
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_ConcurrencyErrors(t *testing.T) {
+	for code, expected := range map[string]string{
+		"var ch = channel(); ch.close(); ch.send(1);":                   "send on closed channel",
+		"var ch = channel(); ch.close(); ch.close();":                   "close of closed channel",
+		"(spawn (fun () { return nil + 1; })()).join();":                "only two numbers or two strings",
+		"var ch = channel(); ch.close(); select { case ch.send(1) {} }": "send on closed channel",
+		"var ch = channel(); ch.recv();":                                "deadlock: no task can send on channel",
+		"var ch = channel(); ch.send(1);":                               "deadlock: no task can receive from channel",
+		"var ch = channel(); select { case ch.recv() {} }":              "deadlock: no case of select can proceed",
+		"var ch = channel(); (spawn ch.recv()).join();":                 "deadlock: ",
+		"var wg = waitGroup(); wg.add(); wg.wait();":                    "deadlock: wait group counter can never reach zero",
+		"fun* g() { yield channel().recv(); } for (var x in g()) {}":    "deadlock: no task can send on channel",
+	} {
+		interpreter := NewInterpreter(nil)
+		err, _ := interpreter.Run(code)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected error %q for %s, got %v", expected, code, err)
+		}
+	}
+}
+
+// TestInterpreter_SharedState is meant to be run with the race detector
+func TestInterpreter_SharedState(t *testing.T) {
+	code := `
+		class Counter { init() { this.count = 0; } }
+		var counter = Counter();
+		var shared = [];
+		var table = {};
+		var last = 0;
+		var tasks = [];
+		for (var i in range(20)) {
+			tasks.push(spawn (fun (k) {
+				for (var j in range(10)) {
+					shared.push(j);
+					table[k * 10 + j] = j;
+					counter.count = k;
+					last = k;
+				}
+			})(i));
+		}
+		for (var task in tasks) task.join();
+		var size = len(shared);
+		var entries = len(table);`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"size":    NewIntValue(200),
+		"entries": NewIntValue(200),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_AsyncAwait(t *testing.T) {
+	code := `
+		var events = [];
+		async fun double(x) { await sleep(10); events.push(x); return x * 2; }
+		async fun main() {
+			events.push("main");
+			var a = await double(2);
+			var b = await all([double(1), double(3), 7]);
+			var c = await race([sleep(50), double(5)]);
+			return [a, b, c];
+		}
+		var result;
+		main().then(fun (value) { result = value; });
+		setTimeout(fun (name) { events.push(name); }, 5, "timeout");
+		var ticks = 0;
+		var id;
+		id = setInterval(fun () {
+			ticks = ticks + 1;
+			if (ticks == 3) clearInterval(id);
+		}, 4);
+		events.push("sync");`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]string{
+		"events": `["main", "sync", "timeout", 2, 1, 3, 5]`,
+		"result": "[4, [2, 6, 7], 10]",
+		"ticks":  "3",
+	} {
+		value, _ := interpreter.env.Get(name)
+		if fmt.Sprint(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_AsyncErrors(t *testing.T) {
+	for code, expected := range map[string]string{
+		"async fun f() { await sleep(1); return nil + 1; } f();":              "only two numbers or two strings",
+		"async fun f() { return nil + 1; } async fun g() { await f(); } g();": "only two numbers or two strings",
+		"setTimeout(fun () { return nil + 1; }, 1);":                          "only two numbers or two strings",
+		"sleep(-1);": "non-negative number",
+	} {
+		interpreter := NewInterpreter(nil)
+		err, _ := interpreter.Run(code)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected error %q for %s, got %v", expected, code, err)
+		}
+	}
+}
+
+func TestInterpreter_AwaitOutsideAsync(t *testing.T) {
+	for _, code := range []string{
+		"await sleep(1)",
+		"(fun () { return await sleep(1); })()",
+	} {
+		_, err, isRuntimeError := NewInterpreter(nil).Eval(code)
+		if err == nil || !isRuntimeError || !strings.Contains(err.Error(), "await is only allowed in async functions") {
+			t.Fatalf("Expected await error for %s, got %v", code, err)
+		}
+	}
+
+	err, _ := NewInterpreter(nil).Run("fun f() { await sleep(1); } f();")
+	if err == nil || !strings.Contains(err.Error(), "await is only allowed in async functions") {
+		t.Fatalf("Expected await error, got %v", err)
+	}
+
+	value, err, _ := NewInterpreter(nil).Eval("sleep(1)")
+	if err != nil || fmt.Sprint(value) != "<promise nil>" {
+		t.Fatalf("Expected settled promise, got %v, %v", value, err)
+	}
+}
+
+func TestInterpreter_LocalMutualRecursion(t *testing.T) {
+	code := `
+		var isOdd = "shadowed";
+		fun parity(n) {
+			fun isEven(k) { if (k == 0) return true; return isOdd(k - 1); }
+			fun isOdd(k) { if (k == 0) return false; return isEven(k - 1); }
+			return isEven(n);
+		}
+		var even = parity(10);
+		var odd = parity(7);
+		var later;
+		{
+			var early = fun () { return greet(); };
+			fun greet() { return "hello"; }
+			later = early();
+		}`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"even":  NewBooleanValue(true),
+		"odd":   NewBooleanValue(false),
+		"later": NewStringValue("hello"),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_HoistedFunctionCalledEarly(t *testing.T) {
+	code := `
+		fun b() { return "global"; }
+		{
+			fun a() { return b(); }
+			print a();
+			fun b() { return "local"; }
+		}`
+
+	interpreter := NewInterpreter(nil)
+	err, isRuntimeError := interpreter.Run(code)
+	if err == nil || !isRuntimeError || !strings.Contains(err.Error(), "declaration of variable b is not yet done") {
+		t.Fatalf("Expected use before declaration error, got %v", err)
+	}
+}
+
+func TestInterpreter_StaticMembers(t *testing.T) {
+	code := `
+		class Math {
+			static PI = 3.5;
+			static calls;
+			static square(x) { Math.calls = (Math.calls or 0) + 1; return x * x; }
+			static circle(r) { return Math.PI * Math.square(r); }
+			area() { return Math.square(2); }
+		}
+		class Geometry < Math { static cube(x) { return x * Geometry.square(x); } }
+		class Point { static ORIGIN = Point(0, 0); init(x, y) { this.x = x; this.y = y; } }
+		class Rounded < Math {
+			static TAU = super.PI * 2;
+			static square(x) { return super.square(x) + 1; }
+			area() { return super.area() + 1; }
+		}
+		var circle = Math.circle(2);
+		var area = Math().area();
+		var cube = Geometry.cube(3);
+		Geometry.PI = 3;
+		var pi = Math.PI;
+		var shadowed = Geometry.PI;
+		var calls = Math.calls;
+		var origin = Point.ORIGIN.x;
+		var rounded = Rounded.square(3);
+		var tau = Rounded.TAU;
+		var roundedArea = Rounded().area();`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"circle":      NewNumValue(14),
+		"area":        NewIntValue(4),
+		"cube":        NewIntValue(27),
+		"pi":          NewNumValue(3.5),
+		"shadowed":    NewIntValue(3),
+		"calls":       NewIntValue(3),
+		"origin":      NewIntValue(0),
+		"rounded":     NewIntValue(10),
+		"tau":         NewNumValue(7),
+		"roundedArea": NewIntValue(5),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+
+	errRun, _ := NewInterpreter(nil).Run("class A {} A.missing;")
+	if errRun == nil || !strings.Contains(errRun.Error(), "no static member with name 'missing'") {
+		t.Fatalf("Expected error for missing static member, got %v", errRun)
+	}
+}
+
+func TestInterpreter_Accessors(t *testing.T) {
+	code := `
+		class Rect {
+			init(w, h) { this.w = w; this.h = h; }
+			get area() { return this.w * this.h; }
+			set area(value) { this.w = value / this.h; }
+			get(x) { return x; }
+		}
+		class Square < Rect {
+			init(n) { super.init(n, n); }
+			get side() { return this.w; }
+			get area() { return super.area + 1; }
+		}
+		var rect = Rect(2, 3);
+		var area = rect.area;
+		rect.area = 12;
+		var width = rect.w;
+		var method = rect.get(5);
+		var square = Square(4);
+		var squareArea = square.area;
+		square.area = 32;
+		var side = square.side;`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"area":       NewIntValue(6),
+		"width":      NewIntValue(4),
+		"method":     NewIntValue(5),
+		"squareArea": NewIntValue(17),
+		"side":       NewIntValue(8),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_AccessorOverrides(t *testing.T) {
+	code := `
+		class A { get area() { return "getter"; } set size(v) { this.w = v; } }
+		class B < A { area() { return "method"; } size() { return "size"; } }
+		class C { area() { return "method"; } }
+		class D < C { get area() { return "getter"; } }
+		var b = B();
+		var method = b.area();
+		b.size = 3;
+		var size = b.size;
+		var getter = D().area;`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"method": NewStringValue("method"),
+		"size":   NewIntValue(3),
+		"getter": NewStringValue("getter"),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
+
+func TestInterpreter_AccessorErrors(t *testing.T) {
+	defer SetMaxCallDepth(maxCallDepth)
+	SetMaxCallDepth(100)
+
+	for code, expected := range map[string]string{
+		"class A { get x() { return 1; } } A().x = 2;":                        "property x is read-only",
+		"class A { get x() { return 1; } } class B < A {} B().x = 2;":         "property x is read-only",
+		"class A { set x(v) {} } print A().x;":                                "property x is write-only",
+		"class A { x() {} } class B < A { get x() { return 1; } } B().x = 2;": "property x is read-only",
+		"class A { x() {} } class B < A { set x(v) {} } print B().x;":         "property x is write-only",
+		"class A { get x() { return this.x; } } print A().x;":                 "stack overflow",
+		"class A { set x(v) { this.x = v; } } A().x = 1;":                     "stack overflow",
+	} {
+		interpreter := NewInterpreter(nil)
+		err, _ := interpreter.Run(code)
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("Expected error %q for %s, got %v", expected, code, err)
+		}
+	}
+}
+
+func TestInterpreter_GeneratorClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+	code := `
+		var cleanups = 0;
+		fun cleanup() { cleanups = cleanups + 1; }
+		fun* naturals() { defer cleanup(); var i = 0; while (true) { yield i; i = i + 1; } }
+		var sum = 0;
+		for (var i in range(20)) {
+			for (var n in naturals()) { if (n == 3) break; sum = sum + n; }
+		}
+		var closed = cleanups;
+		fun abandon() { var g = naturals(); g.next(); }
+		for (var i in range(20)) abandon();`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"sum":    NewIntValue(60),
+		"closed": NewIntValue(20),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+
+	// abandoned generators are stopped once they are garbage collected
+	for i := 0; runtime.NumGoroutine() > before; i++ {
+		if i == 100 {
+			t.Fatalf("Expected %d goroutines, got %d", before, runtime.NumGoroutine())
+		}
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInterpreter_CyclicCollections(t *testing.T) {
+	code := `
+		var a = [1];
+		a.push(a);
+		var b = [1];
+		b.push(b);
+		var m = {};
+		m["self"] = m;
+		m["list"] = a;
+		var same = a == a;
+		var equal = a == b;
+		var different = a == [1, [1]];
+		var text = "${a}";
+		var mapText = "${m}";`
+
+	interpreter := NewInterpreter(nil)
+	err, _ := interpreter.Run(code)
+	if err != nil {
+		t.Fatalf("interpreter.Run() error = %v", err)
+	}
+	for name, expected := range map[string]Value{
+		"same":      NewBooleanValue(true),
+		"equal":     NewBooleanValue(true),
+		"different": NewBooleanValue(false),
+		"text":      NewStringValue("[1, [...]]"),
+		"mapText":   NewStringValue(`{"self": {...}, "list": [1, [...]]}`),
+	} {
+		value, _ := interpreter.env.Get(name)
+		if !value.isEqualTo(expected) {
+			t.Fatalf("Expected %s to be %s, got %s", name, expected, value)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+)
+
+// valueIterator walks through the elements of an iterable value
+type valueIterator interface {
+	hasNext() (bool, error)
+	next() (Value, error)
+}
+
+// iteratorCloser is implemented by iterators which hold resources until they
+// are exhausted. close is called when a for-in loop is left early.
+type iteratorCloser interface {
+	close()
+}
+
+// iterable is implemented by all values that can be used in a for-in loop
+type iterable interface {
+	iterate() (valueIterator, error)
+}
+
+// iterate creates an iterator for the value. Methods of iterable instances
+// are called at the given call depth.
+func iterate(depth int, value Value) (valueIterator, error) {
+	if instance, ok := value.(*InstanceValue); ok {
+		return instance.iterateAt(depth)
+	}
+	iterableValue, ok := value.(iterable)
+	if !ok {
+		return nil, fmt.Errorf("value %s is not iterable", value)
+	}
+	return iterableValue.iterate()
+}
+
+// collect returns all elements of an iterable value
+func collect(depth int, value Value) ([]Value, error) {
+	iterator, err := iterate(depth, value)
+	if err != nil {
+		return nil, err
+	}
+	var elements []Value
+	for {
+		hasNext, err := iterator.hasNext()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			return elements, nil
+		}
+		element, err := iterator.next()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+}
+
+// instanceIterator adapts an instance providing hasNext() and next() methods
+type instanceIterator struct {
+	instance *InstanceValue
+	depth    int
+}
+
+func (it *instanceIterator) hasNext() (bool, error) {
+	value, err := it.callMethod("hasNext")
+	if err != nil {
+		return false, err
+	}
+	return value.isTruthy(), nil
+}
+
+func (it *instanceIterator) next() (Value, error) {
+	return it.callMethod("next")
+}
+
+func (it *instanceIterator) callMethod(name string) (Value, error) {
+	method, err := it.instance.getMethod(it.depth, name)
+	if err != nil {
+		return nil, fmt.Errorf("iterator of class %s has no method %s", it.instance.class.name, name)
+	}
+	return callAt(method, it.depth, nil, nil)
+}
+
+type rangeIterator struct {
+	rangeValue *RangeValue
+	current    float64
+}
+
+func (it *rangeIterator) hasNext() (bool, error) {
+	if it.rangeValue.step > 0 {
+		return it.current < it.rangeValue.stop, nil
+	} else {
+		return it.current > it.rangeValue.stop, nil
+	}
+}
+
+func (it *rangeIterator) next() (Value, error) {
+	ret := it.rangeValue.bound(it.current)
+	it.current += it.rangeValue.step
+	return ret, nil
+}
+
+type stringIterator struct {
+	characters []rune
+	index      int
+}
+
+func (it *stringIterator) hasNext() (bool, error) {
+	return it.index < len(it.characters), nil
+}
+
+func (it *stringIterator) next() (Value, error) {
+	if it.index >= len(it.characters) {
+		return nil, fmt.Errorf("no more characters")
+	}
+	ret := NewStringValue(string(it.characters[it.index]))
+	it.index++
+	return ret, nil
+}
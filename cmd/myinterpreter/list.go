@@ -0,0 +1,322 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ListValue is a mutable sequence. Lists can be shared by spawned tasks, so
+// every operation locks mu. Lox code like comparison functions is never run
+// while holding the lock.
+type ListValue struct {
+	elements []Value
+	mu       sync.Mutex
+}
+
+func NewListValue(elements []Value) *ListValue {
+	return &ListValue{elements: elements}
+}
+
+// snapshot returns a copy of the elements
+func (l *ListValue) snapshot() []Value {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.elements)
+}
+
+func (l *ListValue) getType() ValueType {
+	return VtList
+}
+
+func (l *ListValue) isEqualTo(value Value) bool {
+	return l.equals(value, nil)
+}
+
+func (l *ListValue) equals(value Value, visiting []valuePair) bool {
+	other, ok := value.(*ListValue)
+	if !ok {
+		return false
+	}
+	if l == other {
+		return true
+	}
+	visiting, entered := enterPair(l, other, visiting)
+	if !entered {
+		return true
+	}
+	elements, otherElements := l.snapshot(), other.snapshot()
+	if len(elements) != len(otherElements) {
+		return false
+	}
+	for i, element := range elements {
+		if !equalNested(element, otherElements[i], visiting) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *ListValue) isTruthy() bool {
+	return true
+}
+
+func (l *ListValue) String() string {
+	return l.repr(nil)
+}
+
+func (l *ListValue) repr(visiting []Value) string {
+	visiting, entered := enterNested(l, visiting)
+	if !entered {
+		return "[...]"
+	}
+	var elements []string
+	for _, element := range l.snapshot() {
+		elements = append(elements, reprNested(element, visiting))
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+func (l *ListValue) length() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.elements)
+}
+
+func (l *ListValue) getIndex(index Value) (Value, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pos, err := normalizeIndex(index, len(l.elements))
+	if err != nil {
+		return nil, fmt.Errorf("list %w", err)
+	}
+	return l.elements[pos], nil
+}
+
+func (l *ListValue) setIndex(index Value, element Value) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pos, err := normalizeIndex(index, len(l.elements))
+	if err != nil {
+		return fmt.Errorf("list %w", err)
+	}
+	l.elements[pos] = element
+	return nil
+}
+
+func (l *ListValue) slice(start, end Value) (Value, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	from, to, err := sliceBounds(start, end, len(l.elements))
+	if err != nil {
+		return nil, err
+	}
+	elements := make([]Value, to-from)
+	copy(elements, l.elements[from:to])
+	return NewListValue(elements), nil
+}
+
+func (l *ListValue) iterate() (valueIterator, error) {
+	return &listIterator{list: l}, nil
+}
+
+func (l *ListValue) getMember(name string) (Value, error) {
+	method, ok := listMethods[name]
+	if !ok {
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+	return NewBuiltinFuncValueAt(name, method.minArity, method.maxArity, func(depth int, args []Value) (Value, error) {
+		return method.fn(depth, l, args)
+	}), nil
+}
+
+func (l *ListValue) indexOf(value Value) int {
+	for i, element := range l.snapshot() {
+		if element.isEqualTo(value) {
+			return i
+		}
+	}
+	return -1
+}
+
+// listMethod is a method of lists, which is called with between minArity and
+// maxArity arguments
+type listMethod struct {
+	minArity int
+	maxArity int
+	fn       func(depth int, l *ListValue, args []Value) (Value, error)
+}
+
+var listMethods = map[string]listMethod{
+	"push":     {1, variadic, listPush},
+	"pop":      {0, 1, listPop},
+	"insert":   {2, 2, listInsert},
+	"remove":   {1, 1, listRemove},
+	"contains": {1, 1, listContains},
+	"indexOf":  {1, 1, listIndexOf},
+	"sort":     {0, 1, listSort},
+	"reverse":  {0, 0, listReverse},
+}
+
+func listPush(depth int, l *ListValue, args []Value) (Value, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.elements = append(l.elements, args...)
+	return NewNilValue(), nil
+}
+
+func listPop(depth int, l *ListValue, args []Value) (Value, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.elements) == 0 {
+		return nil, errors.New("pop() called on empty list")
+	}
+	pos := len(l.elements) - 1
+	if len(args) == 1 {
+		var err error
+		pos, err = normalizeIndex(args[0], len(l.elements))
+		if err != nil {
+			return nil, fmt.Errorf("pop() %w", err)
+		}
+	}
+	ret := l.elements[pos]
+	l.elements = append(l.elements[:pos], l.elements[pos+1:]...)
+	return ret, nil
+}
+
+func listInsert(depth int, l *ListValue, args []Value) (Value, error) {
+	pos, err := valueToInt(args[0])
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if pos < 0 {
+		pos += len(l.elements)
+	}
+	if pos < 0 || pos > len(l.elements) {
+		return nil, fmt.Errorf("insert() index %s out of range for length %d", args[0], len(l.elements))
+	}
+	l.elements = append(l.elements, nil)
+	copy(l.elements[pos+1:], l.elements[pos:])
+	l.elements[pos] = args[1]
+	return NewNilValue(), nil
+}
+
+func listRemove(depth int, l *ListValue, args []Value) (Value, error) {
+	pos := l.indexOf(args[0])
+	if pos == -1 {
+		return NewBooleanValue(false), nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if pos >= len(l.elements) || !l.elements[pos].isEqualTo(args[0]) {
+		// the list has been changed concurrently
+		pos = slices.IndexFunc(l.elements, args[0].isEqualTo)
+		if pos == -1 {
+			return NewBooleanValue(false), nil
+		}
+	}
+	l.elements = append(l.elements[:pos], l.elements[pos+1:]...)
+	return NewBooleanValue(true), nil
+}
+
+func listContains(depth int, l *ListValue, args []Value) (Value, error) {
+	return NewBooleanValue(l.indexOf(args[0]) != -1), nil
+}
+
+func listIndexOf(depth int, l *ListValue, args []Value) (Value, error) {
+	return NewIntValue(int64(l.indexOf(args[0]))), nil
+}
+
+// listSort sorts the list in place. Without arguments numbers and strings are
+// sorted in ascending order. Optionally a comparison function can be passed
+// which returns a negative number if its first argument is less than the second.
+// It is called one level below the given call depth of sort().
+func listSort(depth int, l *ListValue, args []Value) (Value, error) {
+	var compare func(a, b Value) (bool, error)
+
+	if len(args) == 0 {
+		compare = lessThan
+	} else {
+		fn, ok := args[0].(callable)
+		if !ok {
+			return nil, errors.New("sort() expects a comparison function")
+		}
+		compare = func(a, b Value) (bool, error) {
+			result, err := callAt(fn, depth+1, []Value{a, b}, nil)
+			if err != nil {
+				return false, err
+			}
+			if !isNumber(result) {
+				return false, fmt.Errorf("comparison function must return a number but returned %s", result)
+			}
+			return evalComparison("<", result, NewIntValue(0)), nil
+		}
+	}
+
+	// the comparison function may access the list, so a copy is sorted
+	elements := l.snapshot()
+	var err error
+	sort.SliceStable(elements, func(i, j int) bool {
+		if err != nil {
+			return false
+		}
+		var less bool
+		less, err = compare(elements[i], elements[j])
+		return less
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.elements = elements
+	return NewNilValue(), nil
+}
+
+func listReverse(depth int, l *ListValue, args []Value) (Value, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, j := 0, len(l.elements)-1; i < j; i, j = i+1, j-1 {
+		l.elements[i], l.elements[j] = l.elements[j], l.elements[i]
+	}
+	return NewNilValue(), nil
+}
+
+func lessThan(a, b Value) (bool, error) {
+	if isNumber(a) && isNumber(b) {
+		return evalComparison("<", a, b), nil
+	}
+	switch left := a.(type) {
+	case *StringValue:
+		right, ok := b.(*StringValue)
+		if ok {
+			return left.Value < right.Value, nil
+		}
+	}
+	return false, fmt.Errorf("cannot compare %s and %s", a, b)
+}
+
+type listIterator struct {
+	list  *ListValue
+	index int
+}
+
+func (it *listIterator) hasNext() (bool, error) {
+	return it.index < it.list.length(), nil
+}
+
+func (it *listIterator) next() (Value, error) {
+	it.list.mu.Lock()
+	defer it.list.mu.Unlock()
+	if it.index >= len(it.list.elements) {
+		return nil, errors.New("no more elements")
+	}
+	ret := it.list.elements[it.index]
+	it.index++
+	return ret, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
 )
 
 func main() {
@@ -13,6 +14,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	if depth, ok := os.LookupEnv("LOX_MAX_CALL_DEPTH"); ok {
+		maxDepth, err := strconv.Atoi(depth)
+		if err != nil || maxDepth < 1 {
+			_, _ = fmt.Fprintf(os.Stderr, "Invalid LOX_MAX_CALL_DEPTH: %s\n", depth)
+			os.Exit(1)
+		}
+		SetMaxCallDepth(maxDepth)
+	}
+
 	command := os.Args[1]
 
 	switch command {
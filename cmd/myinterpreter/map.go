@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MapValue is a hash map which keeps its keys in insertion order
+type MapValue struct {
+	table *valueTable
+}
+
+func NewMapValue() *MapValue {
+	return &MapValue{newValueTable()}
+}
+
+func (m *MapValue) getType() ValueType {
+	return VtMap
+}
+
+func (m *MapValue) isEqualTo(value Value) bool {
+	return m.equals(value, nil)
+}
+
+func (m *MapValue) equals(value Value, visiting []valuePair) bool {
+	other, ok := value.(*MapValue)
+	if !ok || m.table.size() != other.table.size() {
+		return false
+	}
+	if m == other {
+		return true
+	}
+	visiting, entered := enterPair(m, other, visiting)
+	if !entered {
+		return true
+	}
+	for _, entry := range m.table.liveEntries() {
+		otherEntry, err := other.table.lookup(1, entry.key)
+		if err != nil || otherEntry == nil || !equalNested(entry.value, otherEntry.value, visiting) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *MapValue) isTruthy() bool {
+	return true
+}
+
+func (m *MapValue) String() string {
+	return m.repr(nil)
+}
+
+func (m *MapValue) repr(visiting []Value) string {
+	visiting, entered := enterNested(m, visiting)
+	if !entered {
+		return "{...}"
+	}
+	var entries []string
+	for _, entry := range m.table.liveEntries() {
+		entries = append(entries, reprNested(entry.key, visiting)+": "+reprNested(entry.value, visiting))
+	}
+	return "{" + strings.Join(entries, ", ") + "}"
+}
+
+func (m *MapValue) length() int {
+	return m.table.size()
+}
+
+func (m *MapValue) getIndex(key Value) (Value, error) {
+	return m.getIndexAt(1, key)
+}
+
+// getIndexAt looks up key, calling hash() and equals() of instances at the
+// given call depth
+func (m *MapValue) getIndexAt(depth int, key Value) (Value, error) {
+	entry, err := m.table.lookup(depth, key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("key %s not found", reprValue(key))
+	}
+	return entry.value, nil
+}
+
+func (m *MapValue) setIndex(key Value, value Value) error {
+	return m.setIndexAt(1, key, value)
+}
+
+// setIndexAt stores value under key, calling hash() and equals() of instances
+// at the given call depth
+func (m *MapValue) setIndexAt(depth int, key Value, value Value) error {
+	return m.table.put(depth, key, value)
+}
+
+// iterate walks through the keys of the map in insertion order
+func (m *MapValue) iterate() (valueIterator, error) {
+	var keys []Value
+	for _, entry := range m.table.liveEntries() {
+		keys = append(keys, entry.key)
+	}
+	return &listIterator{list: NewListValue(keys)}, nil
+}
+
+func (m *MapValue) getMember(name string) (Value, error) {
+	method, ok := mapMethods[name]
+	if !ok {
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+	return NewBuiltinFuncValueAt(name, method.minArity, method.maxArity, func(depth int, args []Value) (Value, error) {
+		return method.fn(depth, m, args)
+	}), nil
+}
+
+// mapMethod is a method of maps, which is called with between minArity and
+// maxArity arguments
+type mapMethod struct {
+	minArity int
+	maxArity int
+	fn       func(depth int, m *MapValue, args []Value) (Value, error)
+}
+
+var mapMethods = map[string]mapMethod{
+	"has":     {1, 1, mapHas},
+	"remove":  {1, 1, mapRemove},
+	"keys":    {0, 0, mapKeys},
+	"values":  {0, 0, mapValues},
+	"entries": {0, 0, mapEntries},
+}
+
+func mapHas(depth int, m *MapValue, args []Value) (Value, error) {
+	entry, err := m.table.lookup(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(entry != nil), nil
+}
+
+func mapRemove(depth int, m *MapValue, args []Value) (Value, error) {
+	removed, err := m.table.remove(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(removed), nil
+}
+
+func mapKeys(depth int, m *MapValue, args []Value) (Value, error) {
+	var keys []Value
+	for _, entry := range m.table.liveEntries() {
+		keys = append(keys, entry.key)
+	}
+	return NewListValue(keys), nil
+}
+
+func mapValues(depth int, m *MapValue, args []Value) (Value, error) {
+	var values []Value
+	for _, entry := range m.table.liveEntries() {
+		values = append(values, entry.value)
+	}
+	return NewListValue(values), nil
+}
+
+// mapEntries returns the entries of the map as list of [key, value] pairs
+func mapEntries(depth int, m *MapValue, args []Value) (Value, error) {
+	var entries []Value
+	for _, entry := range m.table.liveEntries() {
+		entries = append(entries, NewListValue([]Value{entry.key, entry.value}))
+	}
+	return NewListValue(entries), nil
+}
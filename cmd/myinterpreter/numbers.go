@@ -0,0 +1,235 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Promotion rules for arithmetic operations:
+//   - integer +, - and * integer yield an integer. Results exceeding the
+//     range of int64 are promoted to arbitrary precision.
+//   - integer / integer yields an integer if the division is exact and a
+//     float otherwise.
+//   - if one of the operands is a float, the operation is carried out on floats.
+
+func isNumber(value Value) bool {
+	switch value.(type) {
+	case *NumValue, *IntValue:
+		return true
+	default:
+		return false
+	}
+}
+
+func toFloat(value Value) float64 {
+	switch num := value.(type) {
+	case *NumValue:
+		return num.Value
+	case *IntValue:
+		return num.toFloat()
+	default:
+		return math.NaN()
+	}
+}
+
+// evalArithmetic applies one of the operators +, -, * and / to two numbers
+func evalArithmetic(op string, left, right Value) Value {
+	leftInt, leftIsInt := left.(*IntValue)
+	rightInt, rightIsInt := right.(*IntValue)
+	if leftIsInt && rightIsInt {
+		ret := intArithmetic(op, leftInt, rightInt)
+		if ret != nil {
+			return ret
+		}
+	}
+
+	leftNum, rightNum := toFloat(left), toFloat(right)
+	switch op {
+	case "+":
+		return NewNumValue(leftNum + rightNum)
+	case "-":
+		return NewNumValue(leftNum - rightNum)
+	case "*":
+		return NewNumValue(leftNum * rightNum)
+	default:
+		return NewNumValue(leftNum / rightNum)
+	}
+}
+
+// intArithmetic returns nil if the result of the operation is no integer
+func intArithmetic(op string, left, right *IntValue) Value {
+	if left.big == nil && right.big == nil {
+		a, b := left.small, right.small
+		switch op {
+		case "+":
+			sum := a + b
+			if (a >= 0) == (b >= 0) && (sum >= 0) != (a >= 0) {
+				break // overflow
+			}
+			return NewIntValue(sum)
+		case "-":
+			diff := a - b
+			if (a >= 0) != (b >= 0) && (diff >= 0) != (a >= 0) {
+				break // overflow
+			}
+			return NewIntValue(diff)
+		case "*":
+			if a == 0 || b == 0 {
+				return NewIntValue(0)
+			}
+			product := a * b
+			if product/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+				break // overflow
+			}
+			return NewIntValue(product)
+		case "/":
+			if b == 0 || a%b != 0 {
+				return nil
+			}
+			if a == math.MinInt64 && b == -1 {
+				break // overflow
+			}
+			return NewIntValue(a / b)
+		}
+	}
+
+	a, b := left.toBig(), right.toBig()
+	switch op {
+	case "+":
+		return NewBigIntValue(new(big.Int).Add(a, b))
+	case "-":
+		return NewBigIntValue(new(big.Int).Sub(a, b))
+	case "*":
+		return NewBigIntValue(new(big.Int).Mul(a, b))
+	default:
+		if b.Sign() == 0 {
+			return nil
+		}
+		quotient, remainder := new(big.Int).QuoRem(a, b, new(big.Int))
+		if remainder.Sign() != 0 {
+			return nil
+		}
+		return NewBigIntValue(quotient)
+	}
+}
+
+// evalComparison applies one of the operators <, <=, > and >= to two numbers
+func evalComparison(op string, left, right Value) bool {
+	leftInt, leftIsInt := left.(*IntValue)
+	rightInt, rightIsInt := right.(*IntValue)
+	if leftIsInt && rightIsInt {
+		cmp := leftInt.toBig().Cmp(rightInt.toBig())
+		switch op {
+		case "<":
+			return cmp < 0
+		case "<=":
+			return cmp <= 0
+		case ">":
+			return cmp > 0
+		default:
+			return cmp >= 0
+		}
+	}
+
+	leftNum, rightNum := toFloat(left), toFloat(right)
+	switch op {
+	case "<":
+		return leftNum < rightNum
+	case "<=":
+		return leftNum <= rightNum
+	case ">":
+		return leftNum > rightNum
+	default:
+		return leftNum >= rightNum
+	}
+}
+
+func negate(value Value) Value {
+	switch num := value.(type) {
+	case *IntValue:
+		if num.big == nil && num.small != math.MinInt64 {
+			return NewIntValue(-num.small)
+		}
+		return NewBigIntValue(new(big.Int).Neg(num.toBig()))
+	default:
+		return NewNumValue(-toFloat(value))
+	}
+}
+
+func intFn(args []Value) (Value, error) {
+	switch value := args[0].(type) {
+	case *IntValue:
+		return value, nil
+	case *NumValue:
+		if math.IsNaN(value.Value) || math.IsInf(value.Value, 0) {
+			return nil, fmt.Errorf("cannot convert %s to an integer", value)
+		}
+		ret, _ := big.NewFloat(math.Trunc(value.Value)).Int(nil)
+		return NewBigIntValue(ret), nil
+	case *StringValue:
+		ret, ok := new(big.Int).SetString(strings.TrimSpace(value.Value), 10)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %s to an integer", reprValue(value))
+		}
+		return NewBigIntValue(ret), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to an integer", value)
+	}
+}
+
+func floatFn(args []Value) (Value, error) {
+	switch value := args[0].(type) {
+	case *IntValue, *NumValue:
+		return NewNumValue(toFloat(value)), nil
+	case *StringValue:
+		ret, err := strconv.ParseFloat(strings.TrimSpace(value.Value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot convert %s to a float", reprValue(value))
+		}
+		return NewNumValue(ret), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a float", value)
+	}
+}
+
+// parseNumberLiteral converts the lexeme of a number token to its value.
+// Literals with a decimal point or an exponent are floats, all others are
+// integers.
+func parseNumberLiteral(lexeme string) Value {
+	digits := strings.ReplaceAll(lexeme, "_", "")
+	hasPrefix := len(digits) > 1 && digits[0] == '0' && strings.ContainsAny(digits[1:2], "xXbBoO")
+	if hasPrefix || !strings.ContainsAny(digits, ".eE") {
+		base := 10
+		if hasPrefix {
+			base = 0
+		}
+		value, ok := new(big.Int).SetString(digits, base)
+		if ok {
+			return NewBigIntValue(value)
+		}
+	}
+	value, _ := strconv.ParseFloat(digits, 64)
+	return NewNumValue(value)
+}
+
+// formatFloat returns the shortest representation which parses back to the
+// same value. Very small and very large values use exponent notation.
+func formatFloat(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	}
+	abs := math.Abs(value)
+	if abs == 0 || (abs >= 1e-7 && abs < 1e21) {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(value, 'e', -1, 64), "e")
+	return mantissa + "e" + exponent[:1] + strings.TrimLeft(exponent[1:], "0")
+}
@@ -3,8 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"slices"
 )
 
 type Parser struct {
@@ -84,13 +83,59 @@ func (p *Parser) parseDeclaration(nextToken TokenInfo) (Statement, error) {
 		return p.parseClassDef()
 	case Var:
 		return p.parseVarDecl()
-	case Fun:
+	case Fun, Async:
+		if p.isFunctionExpr() {
+			return p.parseExprStmt()
+		}
 		return p.parseFunctionDef(true, nil)
+	case At:
+		return p.parseDecoratedFunctionDef(nil)
 	default:
 		return p.parseStatement(token)
 	}
 }
 
+// parseDecoratedFunctionDef parses a function or method declaration preceded
+// by decorators, e.g. @memoize fun fib(n) {...}. A decorator is a path
+// expression which may end with a call, like @retry(3).
+func (p *Parser) parseDecoratedFunctionDef(class *ClassDef) (AST, error) {
+	var decorators []Expr
+	for {
+		token, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() != At {
+			break
+		}
+		_, _ = p.advance()
+		decorator, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		decorators = append(decorators, decorator)
+	}
+
+	if class == nil {
+		token, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if (token.GetTokenType() != Fun && token.GetTokenType() != Async) || p.isFunctionExpr() {
+			return nil, errors.New("decorators must be followed by a function declaration")
+		}
+	}
+	function, err := p.parseFunctionDef(class == nil, class)
+	if err != nil {
+		return nil, err
+	}
+	if function.(*FunctionDef).isAccessor() {
+		return nil, errors.New("decorators cannot be applied to getters or setters")
+	}
+	function.(*FunctionDef).decorators = decorators
+	return function, nil
+}
+
 func (p *Parser) parseStatement(nextToken TokenInfo) (Statement, error) {
 	var stmt Statement
 	var token TokenInfo
@@ -110,16 +155,28 @@ func (p *Parser) parseStatement(nextToken TokenInfo) (Statement, error) {
 		stmt, err = p.parsePrintStmt()
 	case Return:
 		stmt, err = p.parseReturnStmt()
+	case Yield:
+		stmt, err = p.parseYieldStmt()
+	case Defer:
+		stmt, err = p.parseDeferStmt()
+	case Select:
+		stmt, err = p.parseSelectStmt()
 	case If:
 		stmt, err = p.parseIfStmt()
 	case While:
 		stmt, err = p.parseWhileStmt()
 	case For:
 		stmt, err = p.parseForStmt()
+	case Break, Continue:
+		stmt, err = p.parseJumpStmt()
 	case LeftBrace:
 		stmt, err = p.parseBlock()
 	default:
-		stmt, err = p.parseExprStmt()
+		if p.isLabel() {
+			stmt, err = p.parseLabeledStmt()
+		} else {
+			stmt, err = p.parseExprStmt()
+		}
 	}
 
 	if err != nil {
@@ -129,6 +186,64 @@ func (p *Parser) parseStatement(nextToken TokenInfo) (Statement, error) {
 	return stmt, nil
 }
 
+func (p *Parser) isLabel() bool {
+	tokens := p.peekNTokens(2)
+	return len(tokens) == 2 &&
+		tokens[0].GetTokenType() == Identifier &&
+		tokens[1].GetTokenType() == Colon
+}
+
+func (p *Parser) parseLabeledStmt() (Statement, error) {
+	label, err := p.consume(Identifier)
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(Colon)
+	if err != nil {
+		return nil, err
+	}
+
+	stmt, err := p.parseStatement(nil)
+	if err != nil {
+		return nil, err
+	}
+	loop, isLoop := stmt.(LoopStatement)
+	if !isLoop {
+		return nil, fmt.Errorf("label %s must be followed by a loop", label.GetLexeme())
+	}
+	loop.setLabel(label.GetLexeme())
+
+	return loop, nil
+}
+
+func (p *Parser) parseJumpStmt() (Statement, error) {
+	keyword, err := p.consume(Break, Continue)
+	if err != nil {
+		return nil, err
+	}
+
+	label := ""
+	token, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if token.GetTokenType() == Identifier {
+		_, _ = p.advance()
+		label = token.GetLexeme()
+	}
+
+	_, err = p.consume(Semicolon)
+	if err != nil {
+		return nil, err
+	}
+
+	if keyword.GetTokenType() == Break {
+		return NewBreakStatement(label), nil
+	} else {
+		return NewContinueStatement(label), nil
+	}
+}
+
 func (p *Parser) parseReturnStmt() (Statement, error) {
 	_, err := p.consume(Return)
 	if err != nil {
@@ -156,6 +271,170 @@ func (p *Parser) parseReturnStmt() (Statement, error) {
 	return NewReturnStatement(expr), nil
 }
 
+func (p *Parser) parseYieldStmt() (Statement, error) {
+	_, err := p.consume(Yield)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = p.consume(Semicolon)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewYieldStatement(expr), nil
+}
+
+func (p *Parser) parseDeferStmt() (Statement, error) {
+	_, err := p.consume(Defer)
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if !isCallExpr(expr) {
+		return nil, errors.New("defer expects a function or method call")
+	}
+
+	_, err = p.consume(Semicolon)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewDeferStatement(expr), nil
+}
+
+// parseSelectStmt parses
+//
+//	select {
+//	  case var msg = channel.recv() { ... }
+//	  case channel.send(value) { ... }
+//	  default { ... }
+//	}
+func (p *Parser) parseSelectStmt() (Statement, error) {
+	_, err := p.consume(Select)
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(LeftBrace)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []SelectCase
+	var defaultCase *Block
+	for {
+		token, err := p.advance()
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightBrace {
+			break
+		}
+		switch {
+		case token.GetTokenType() == Identifier && token.GetLexeme() == "case":
+			selectCase, err := p.parseSelectCase()
+			if err != nil {
+				return nil, err
+			}
+			cases = append(cases, *selectCase)
+		case token.GetTokenType() == Identifier && token.GetLexeme() == "default":
+			if defaultCase != nil {
+				return nil, errors.New("select has more than one default case")
+			}
+			body, err := p.parseBlock()
+			if err != nil {
+				return nil, err
+			}
+			defaultCase = body.(*Block)
+		default:
+			return nil, fmt.Errorf("expected case or default in select but got %s", token.GetLexeme())
+		}
+	}
+
+	if len(cases) == 0 && defaultCase == nil {
+		return nil, errors.New("select needs at least one case")
+	}
+	return NewSelectStatement(cases, defaultCase), nil
+}
+
+func (p *Parser) parseSelectCase() (*SelectCase, error) {
+	ret := &SelectCase{}
+	token, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if token.GetTokenType() == Var {
+		_, _ = p.advance()
+		ident, err := p.consume(Identifier)
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.consume(Equal)
+		if err != nil {
+			return nil, err
+		}
+		ret.variable = ident.GetLexeme()
+	}
+
+	expr, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	invalid := errors.New("select case expects channel.recv() or channel.send(value)")
+	if !isCallExpr(expr) {
+		return nil, invalid
+	}
+	path, isPath := expr.(*BinaryExpr)
+	if !isPath {
+		return nil, invalid
+	}
+	call := path.Right.(*Call)
+	method, isIdentifier := call.callee.(*IdentifierExpr)
+	if !isIdentifier || slices.ContainsFunc(call.argNames, func(name string) bool { return name != "" }) {
+		return nil, invalid
+	}
+	switch {
+	case method.name == "recv" && len(call.args) == 0:
+	case method.name == "send" && len(call.args) == 1 && ret.variable == "":
+		ret.value = call.args[0]
+	default:
+		return nil, invalid
+	}
+	if _, isSpread := ret.value.(*SpreadExpr); isSpread {
+		return nil, invalid
+	}
+	ret.channel = path.Left
+
+	body, err := p.parseBlock()
+	if err != nil {
+		return nil, err
+	}
+	ret.body = *body.(*Block)
+	return ret, nil
+}
+
+func isCallExpr(expr Expr) bool {
+	_, ok := expr.(*Call)
+	if ok {
+		return true
+	}
+	binaryExpr, ok := expr.(*BinaryExpr)
+	if !ok || binaryExpr.Operator.GetTokenType() != Dot {
+		return false
+	}
+	_, ok = binaryExpr.Right.(*Call)
+	return ok
+}
+
 func (p *Parser) parseForStmt() (Statement, error) {
 	_, err := p.consume(For)
 	if err != nil {
@@ -166,6 +445,10 @@ func (p *Parser) parseForStmt() (Statement, error) {
 		return nil, err
 	}
 
+	if p.isForInHeader() {
+		return p.parseForInStmt()
+	}
+
 	var initializer Statement
 	nextToken, err := p.peek()
 	if err != nil {
@@ -225,6 +508,42 @@ func (p *Parser) parseForStmt() (Statement, error) {
 	return NewForStatement(initializer, condition, increment, statement), nil
 }
 
+func (p *Parser) isForInHeader() bool {
+	tokens := p.peekNTokens(3)
+	return len(tokens) == 3 &&
+		tokens[0].GetTokenType() == Var &&
+		tokens[1].GetTokenType() == Identifier &&
+		tokens[2].GetTokenType() == In
+}
+
+func (p *Parser) parseForInStmt() (Statement, error) {
+	_, _ = p.consume(Var)
+	ident, err := p.consume(Identifier)
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(In)
+	if err != nil {
+		return nil, err
+	}
+
+	iterable, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(RightParen)
+	if err != nil {
+		return nil, err
+	}
+
+	statement, err := p.parseStatement(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewForInStatement(ident.GetLexeme(), iterable, statement), nil
+}
+
 func (p *Parser) parseWhileStmt() (Statement, error) {
 	_, err := p.consume(While)
 	if err != nil {
@@ -345,7 +664,21 @@ func (p *Parser) parseClassDef() (AST, error) {
 			break
 		}
 
-		function, errFuncDef := p.parseFunctionDef(false, ret)
+		if p.isStaticField() {
+			err = p.parseStaticField(ret)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var function AST
+		var errFuncDef error
+		if nextToken.GetTokenType() == At {
+			function, errFuncDef = p.parseDecoratedFunctionDef(ret)
+		} else {
+			function, errFuncDef = p.parseFunctionDef(false, ret)
+		}
 		if errFuncDef != nil {
 			return nil, errFuncDef
 		}
@@ -360,17 +693,93 @@ func (p *Parser) parseClassDef() (AST, error) {
 	return ret, nil
 }
 
+// isStaticField checks if the next tokens start a static field of a class
+// like static count = 0; or static count;
+func (p *Parser) isStaticField() bool {
+	tokens := p.peekNTokens(3)
+	return len(tokens) == 3 && tokens[0].GetTokenType() == Static &&
+		tokens[1].GetTokenType() == Identifier &&
+		(tokens[2].GetTokenType() == Equal || tokens[2].GetTokenType() == Semicolon)
+}
+
+func (p *Parser) parseStaticField(class *ClassDef) error {
+	// static takes the place of the var keyword
+	decl, err := p.parseVarDecl()
+	if err != nil {
+		return err
+	}
+	class.addStaticField(*decl.(*VarDecl))
+	return nil
+}
+
+// isAccessor checks if the next tokens start a getter or setter like
+// get area() or set area(value). get and set are no keywords, so they can
+// still be used as names of methods.
+func (p *Parser) isAccessor() bool {
+	tokens := p.peekNTokens(3)
+	return len(tokens) == 3 && tokens[0].GetTokenType() == Identifier &&
+		(tokens[0].GetLexeme() == "get" || tokens[0].GetLexeme() == "set") &&
+		tokens[1].GetTokenType() == Identifier && tokens[2].GetTokenType() == LeftParen
+}
+
+// parseFunctionDef parses a function declaration or method, which may be
+// marked async (async fun name() or async name() in classes) or be a
+// generator (fun* name()). Methods may be static (static name()) or
+// accessors (get name() and set name(value)).
 func (p *Parser) parseFunctionDef(withFun bool, class *ClassDef) (AST, error) {
+	isStatic := false
+	token, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if class != nil && token.GetTokenType() == Static {
+		_, _ = p.advance()
+		isStatic = true
+		token, err = p.peek()
+		if err != nil {
+			return nil, err
+		}
+	}
+	isGetter, isSetter := false, false
+	if class != nil && !isStatic && p.isAccessor() {
+		_, _ = p.advance()
+		isGetter, isSetter = token.GetLexeme() == "get", token.GetLexeme() == "set"
+		token, err = p.peek()
+		if err != nil {
+			return nil, err
+		}
+	}
+	isAsync := false
+	if token.GetTokenType() == Async {
+		_, _ = p.advance()
+		isAsync = true
+	}
 	if withFun {
 		_, err := p.consume(Fun)
 		if err != nil {
 			return nil, err
 		}
 	}
-	ident, err := p.consume(Identifier)
+	isGenerator := false
+	token, err = p.peek()
 	if err != nil {
 		return nil, err
 	}
+	if token.GetTokenType() == Star {
+		if isAsync {
+			return nil, errors.New("async functions cannot be generators")
+		}
+		_, _ = p.advance()
+		isGenerator = true
+	}
+	var name string
+	if class != nil || !p.isFunctionExpr() {
+		ident, err := p.consume(Identifier)
+		if err != nil {
+			return nil, err
+		}
+		name = ident.GetLexeme()
+	}
 	_, err = p.consume(LeftParen)
 	if err != nil {
 		return nil, err
@@ -379,21 +788,96 @@ func (p *Parser) parseFunctionDef(withFun bool, class *ClassDef) (AST, error) {
 	if err != nil {
 		return nil, err
 	}
+	if isGetter && len(params) != 0 {
+		return nil, fmt.Errorf("getter %s must not have parameters", name)
+	}
+	if isSetter && (len(params) != 1 || params[0].isRest) {
+		return nil, fmt.Errorf("setter %s must have exactly one parameter", name)
+	}
 
 	body, err := p.parseBlock()
 	if err != nil {
 		return nil, err
 	}
 
-	return NewFunctionDef(
+	ret := NewFunctionDef(
 		class,
-		ident.GetLexeme(),
+		name,
 		params,
-		*body.(*Block)), nil
+		*body.(*Block))
+	ret.isGenerator = isGenerator
+	ret.isAsync = isAsync
+	ret.isStatic = isStatic
+	ret.isGetter = isGetter
+	ret.isSetter = isSetter
+
+	return ret, nil
+}
+
+// isFunctionExpr checks if the next tokens start an anonymous function, i.e.
+// the optional async and fun keywords and the optional * are directly
+// followed by the parameter list
+func (p *Parser) isFunctionExpr() bool {
+	for n := 1; ; n++ {
+		tokens := p.peekNTokens(n)
+		if len(tokens) < n {
+			return false
+		}
+		switch tokens[n-1].GetTokenType() {
+		case Async, Fun, Star:
+			continue
+		case LeftParen:
+			return true
+		default:
+			return false
+		}
+	}
 }
 
-func (p *Parser) parseParameters() ([]string, error) {
-	var parameters []string
+// isArrowFunction checks if the tokens following an opening parenthesis are
+// the parameter list of an arrow function like (a, b) => a + b
+func (p *Parser) isArrowFunction() bool {
+	depth := 1
+	for n := 1; ; n++ {
+		tokens := p.peekNTokens(n)
+		if len(tokens) < n {
+			return false
+		}
+		switch tokens[n-1].GetTokenType() {
+		case LeftParen:
+			depth++
+		case RightParen:
+			depth--
+			if depth == 0 {
+				tokens = p.peekNTokens(n + 1)
+				return len(tokens) > n && tokens[n].GetTokenType() == Arrow
+			}
+		case EOF:
+			return false
+		}
+	}
+}
+
+// parseArrowFunction parses an arrow function after its opening parenthesis.
+// The body expression is the return value of the function.
+func (p *Parser) parseArrowFunction() (Expr, error) {
+	params, err := p.parseParameters()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(Arrow)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	return NewFunctionExpr(NewFunctionDef(nil, "", params, *NewBlock([]Statement{NewReturnStatement(body)}))), nil
+}
+
+func (p *Parser) parseParameters() ([]Parameter, error) {
+	var parameters []Parameter
 
 	token, err := p.peek()
 	if err != nil {
@@ -405,16 +889,43 @@ func (p *Parser) parseParameters() ([]string, error) {
 	}
 
 	for {
-		if token.GetTokenType() == Identifier {
+		isRest := token.GetTokenType() == Ellipsis
+		if isRest {
 			_, _ = p.advance()
-			parameters = append(parameters, token.GetLexeme())
-		} else {
+			token, err = p.peek()
+			if err != nil {
+				return nil, err
+			}
+		}
+		if token.GetTokenType() != Identifier {
 			return nil, errors.New("expected identifier as parameter")
 		}
+		_, _ = p.advance()
+		param := Parameter{name: token.GetLexeme(), isRest: isRest}
+
 		token, err = p.peek()
 		if err != nil {
 			return nil, err
 		}
+		if isRest {
+			if token.GetTokenType() != RightParen {
+				return nil, fmt.Errorf("rest parameter %s must be the last parameter", param.name)
+			}
+		} else if token.GetTokenType() == Equal {
+			_, _ = p.advance()
+			param.defaultValue, err = p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			token, err = p.peek()
+			if err != nil {
+				return nil, err
+			}
+		} else if len(parameters) > 0 && parameters[len(parameters)-1].defaultValue != nil {
+			return nil, fmt.Errorf("parameter %s without default value follows parameter with default value", param.name)
+		}
+		parameters = append(parameters, param)
+
 		switch token.GetTokenType() {
 		case Comma:
 			_, _ = p.advance()
@@ -433,12 +944,20 @@ func (p *Parser) parseParameters() ([]string, error) {
 
 func (p *Parser) parseVarDecl() (AST, error) {
 	_, _ = p.advance() // consume var token
+	token, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if token.GetTokenType() == LeftParen {
+		return p.parseDestructuringDecl()
+	}
+
 	ident, err := p.consume(Identifier)
 	if err != nil {
 		return nil, err
 	}
 
-	token, err := p.consume(Equal, Semicolon)
+	token, err = p.consume(Equal, Semicolon)
 	if err != nil {
 		return nil, err
 	}
@@ -464,6 +983,71 @@ func (p *Parser) parseVarDecl() (AST, error) {
 	return NewVarDecl(ident.GetLexeme(), expr), nil
 }
 
+func (p *Parser) parseDestructuringDecl() (AST, error) {
+	pattern, err := p.parsePattern()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(Equal)
+	if err != nil {
+		return nil, errors.New("destructuring declaration requires an initializer")
+	}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(Semicolon)
+	if err != nil {
+		return nil, err
+	}
+	return NewDestructuringDecl(pattern, expr), nil
+}
+
+// parsePattern parses a parenthesized list of variable names and nested
+// patterns like (x, (y, z))
+func (p *Parser) parsePattern() (*TupleExpr, error) {
+	_, err := p.consume(LeftParen)
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []Expr
+	for {
+		token, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == LeftParen {
+			pattern, err := p.parsePattern()
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, pattern)
+		} else {
+			ident, err := p.consume(Identifier)
+			if err != nil {
+				return nil, err
+			}
+			elements = append(elements, NewIdentifierExpr(ident.GetLexeme()))
+		}
+
+		token, err = p.consume(Comma, RightParen)
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightParen {
+			break
+		}
+		token, err = p.peek()
+		if err == nil && token.GetTokenType() == RightParen {
+			_, _ = p.advance()
+			break
+		}
+	}
+
+	return NewTupleExpr(elements), nil
+}
+
 func (p *Parser) parsePrintStmt() (AST, error) {
 	_, _ = p.advance() // consume print token
 	expr, err := p.parseExpr()
@@ -525,7 +1109,7 @@ func (p *Parser) parseExpr() (Expr, error) {
 	}
 
 	if !isValidLhs(expr) {
-		return nil, errors.New("left hand side of an assignment must be an identifier or a property")
+		return nil, errors.New("left hand side of an assignment must be an identifier, a property, an index expression or a tuple of these")
 	}
 
 	_, _ = p.consume(Equal)
@@ -539,9 +1123,16 @@ func (p *Parser) parseExpr() (Expr, error) {
 }
 
 func isValidLhs(lhs Expr) bool {
-	_, ok := lhs.(*IdentifierExpr)
-	if ok {
+	switch lhs := lhs.(type) {
+	case *IdentifierExpr, *IndexExpr:
 		return true
+	case *TupleExpr:
+		for _, element := range lhs.elements {
+			if !isValidLhs(element) {
+				return false
+			}
+		}
+		return len(lhs.elements) > 0
 	}
 	binaryExpr, ok := lhs.(*BinaryExpr)
 	if !ok {
@@ -676,8 +1267,7 @@ func (p *Parser) parseAtomic() (Expr, error) {
 	}
 	switch tt := token.GetTokenType(); tt {
 	case Number:
-		value, _ := strconv.ParseFloat(token.GetLexeme(), 64)
-		expr = NewNumberExpr(value)
+		expr = parseNumber(token.GetLexeme())
 	case True:
 		expr = NewBooleanExpr(true)
 	case False:
@@ -685,12 +1275,50 @@ func (p *Parser) parseAtomic() (Expr, error) {
 	case Nil:
 		expr = NewNilExpr()
 	case String:
-		value := strings.Trim(token.GetLexeme(), "\"")
-		expr = NewStringExpr(value)
+		expr, err = p.parseString(token.(*Token))
 	case Identifier, This, Super:
 		expr = NewIdentifierExpr(token.GetLexeme())
 	case LeftParen:
-		expr, err = p.parseGroup()
+		if p.isArrowFunction() {
+			expr, err = p.parseArrowFunction()
+		} else {
+			expr, err = p.parseGroup()
+		}
+	case Fun:
+		var function AST
+		function, err = p.parseFunctionDef(false, nil)
+		if err == nil {
+			expr = NewFunctionExpr(function.(*FunctionDef))
+		}
+	case Async:
+		var function AST
+		_, err = p.consume(Fun)
+		if err == nil {
+			function, err = p.parseFunctionDef(false, nil)
+		}
+		if err == nil {
+			function.(*FunctionDef).isAsync = true
+			expr = NewFunctionExpr(function.(*FunctionDef))
+		}
+	case Await:
+		var value Expr
+		value, err = p.parsePath()
+		if err == nil {
+			return NewAwaitExpr(value), nil
+		}
+	case Spawn:
+		var call Expr
+		call, err = p.parsePath()
+		if err == nil && !isCallExpr(call) {
+			err = errors.New("spawn expects a function or method call")
+		}
+		if err == nil {
+			return NewSpawnExpr(call), nil
+		}
+	case LeftBracket:
+		expr, err = p.parseList()
+	case LeftBrace:
+		expr, err = p.parseMap()
 	case Bang, Minus:
 		expr, err = p.parseUnary(token)
 	default:
@@ -701,20 +1329,48 @@ func (p *Parser) parseAtomic() (Expr, error) {
 		return nil, err
 	}
 
-	nextToken, err := p.peek()
-	if err != nil {
-		return expr, nil
+	return p.parsePostfix(expr)
+}
+
+// parsePostfix parses any sequence of calls, index and slice operations
+// following an atomic expression
+func (p *Parser) parsePostfix(expr Expr) (Expr, error) {
+	var err error
+	for {
+		nextToken, errPeek := p.peek()
+		if errPeek != nil {
+			return expr, nil
+		}
+
+		switch nextToken.GetTokenType() {
+		case LeftParen:
+			expr, err = p.parseCall(expr)
+		case LeftBracket:
+			expr, err = p.parseIndex(expr)
+		default:
+			return expr, nil
+		}
+		if err != nil {
+			return nil, err
+		}
 	}
+}
 
-	if nextToken.GetTokenType() != LeftParen {
-		return expr, nil
-	} else {
-		return p.parseCall(expr)
+func parseNumber(lexeme string) Expr {
+	switch value := parseNumberLiteral(lexeme).(type) {
+	case *IntValue:
+		return NewIntegerExpr(value.toBig())
+	default:
+		return NewNumberExpr(value.(*NumValue).Value)
 	}
 }
 
+// parseCall parses the argument list of a call. Named arguments like
+// connect(port = 80) follow the positional arguments, which may be spread from
+// an iterable like f(...args).
 func (p *Parser) parseCall(callee Expr) (Expr, error) {
 	var args []Expr
+	var argNames []string
 	var arg Expr
 	var token TokenInfo
 	var err error
@@ -729,11 +1385,29 @@ func (p *Parser) parseCall(callee Expr) (Expr, error) {
 		_, _ = p.advance()
 	} else {
 		for {
+			name := ""
+			tokens := p.peekNTokens(2)
+			if len(tokens) == 2 && tokens[0].GetTokenType() == Identifier && tokens[1].GetTokenType() == Equal {
+				_, _ = p.advance()
+				_, _ = p.advance()
+				name = tokens[0].GetLexeme()
+			} else if len(argNames) > 0 && argNames[len(argNames)-1] != "" {
+				return nil, errors.New("positional argument follows named argument")
+			}
+
+			isSpread := len(tokens) > 0 && tokens[0].GetTokenType() == Ellipsis
+			if isSpread {
+				_, _ = p.advance()
+			}
 			arg, err = p.parseExpr()
 			if err != nil {
 				return nil, err
 			}
+			if isSpread {
+				arg = NewSpreadExpr(arg)
+			}
 			args = append(args, arg)
+			argNames = append(argNames, name)
 
 			token, err = p.peek()
 			if err != nil {
@@ -748,13 +1422,147 @@ func (p *Parser) parseCall(callee Expr) (Expr, error) {
 		}
 	}
 
+	return NewCall(callee, args, argNames), nil
+}
+
+func (p *Parser) parseIndex(object Expr) (Expr, error) {
+	var start, end Expr
+	var err error
+
+	_, _ = p.consume(LeftBracket)
+
+	token, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if token.GetTokenType() != Colon {
+		start, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	token, err = p.consume(RightBracket, Colon)
+	if err != nil {
+		return nil, err
+	}
+	if token.GetTokenType() == RightBracket {
+		if start == nil {
+			return nil, errors.New("expected index expression")
+		}
+		return NewIndexExpr(object, start), nil
+	}
+
 	token, err = p.peek()
-	if err != nil || token.GetTokenType() != LeftParen {
-		return &Call{callee, args}, nil
-	} else {
-		callee = &Call{callee, args}
-		return p.parseCall(callee)
+	if err != nil {
+		return nil, err
+	}
+	if token.GetTokenType() != RightBracket {
+		end, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(RightBracket)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSliceExpr(object, start, end), nil
+}
+
+func (p *Parser) parseList() (Expr, error) {
+	var elements []Expr
+
+	for {
+		token, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightBracket {
+			_, _ = p.advance()
+			break
+		}
+
+		element, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		token, err = p.consume(Comma, RightBracket)
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightBracket {
+			break
+		}
+	}
+
+	return NewListExpr(elements), nil
+}
+
+func (p *Parser) parseMap() (Expr, error) {
+	var keys, values []Expr
+
+	for {
+		token, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightBrace {
+			_, _ = p.advance()
+			break
+		}
+
+		key, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.consume(Colon)
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+		values = append(values, value)
+
+		token, err = p.consume(Comma, RightBrace)
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightBrace {
+			break
+		}
+	}
+
+	return NewMapExpr(keys, values), nil
+}
+
+func (p *Parser) parseString(token *Token) (Expr, error) {
+	if token.segments == nil {
+		return NewStringExpr(token.literal), nil
 	}
+
+	var parts []Expr
+	for _, segment := range token.segments {
+		if !segment.isExpression {
+			if segment.text != "" {
+				parts = append(parts, NewStringExpr(segment.text))
+			}
+			continue
+		}
+		part, err := NewParser(segment.text).ParseExpression()
+		if err != nil {
+			return nil, fmt.Errorf("invalid interpolation ${%s}: %w", segment.text, err)
+		}
+		parts = append(parts, part)
+	}
+
+	return NewInterpolationExpr(parts), nil
 }
 
 func (p *Parser) parseUnary(operator TokenInfo) (Expr, error) {
@@ -765,7 +1573,18 @@ func (p *Parser) parseUnary(operator TokenInfo) (Expr, error) {
 	return NewUnaryExpr(operator, value), nil
 }
 
+// parseGroup parses a parenthesized expression or a tuple. Apart from the
+// empty tuple () tuples contain at least one comma, e.g. (1,)
 func (p *Parser) parseGroup() (Expr, error) {
+	token, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if token.GetTokenType() == RightParen {
+		_, _ = p.advance()
+		return NewTupleExpr(nil), nil
+	}
+
 	inner, err := p.parseExpr()
 	if err != nil {
 		return nil, err
@@ -774,10 +1593,45 @@ func (p *Parser) parseGroup() (Expr, error) {
 	if err != nil {
 		return nil, err
 	}
-	if tok.GetTokenType() != RightParen {
+	switch tok.GetTokenType() {
+	case RightParen:
+		return NewGroupExpr(inner), nil
+	case Comma:
+		return p.parseTuple(inner)
+	default:
 		return nil, errors.New("expected right paren")
 	}
-	return NewGroupExpr(inner), nil
+}
+
+// parseTuple parses the remaining elements of a tuple after the first comma
+func (p *Parser) parseTuple(first Expr) (Expr, error) {
+	elements := []Expr{first}
+	for {
+		token, err := p.peek()
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightParen {
+			_, _ = p.advance()
+			break
+		}
+
+		element, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+
+		token, err = p.consume(Comma, RightParen)
+		if err != nil {
+			return nil, err
+		}
+		if token.GetTokenType() == RightParen {
+			break
+		}
+	}
+
+	return NewTupleExpr(elements), nil
 }
 
 func (p *Parser) advance() (TokenInfo, error) {
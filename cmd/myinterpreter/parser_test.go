@@ -163,3 +163,178 @@ func TestParser_ParseProperty(t *testing.T) {
 
 	ast.accept(NewAstPrinter())
 }
+
+func TestParser_ParseArrowFunction(t *testing.T) {
+	code := "(a, b) => (a + b) * 2"
+	parser := NewParser(code)
+
+	ast, err := parser.ParseExpression()
+	if err != nil {
+		t.Fatalf("parser.ParseExpression() error = %v", err)
+	}
+	function, ok := ast.(*FunctionExpr)
+	if !ok {
+		t.Fatalf("Expected function expression, got %T", ast)
+	}
+	assertEq(2, len(function.function.parameters), t)
+}
+
+func TestParser_ParseDecorators(t *testing.T) {
+	code := `
+	@lib.cache
+	@retry(3)
+	fun fetch(url) {}`
+
+	parser := NewParser(code)
+	ast, err := parser.ParseProgram()
+	if err != nil {
+		t.Fatalf("parser.ParseProgram() error = %v", err)
+	}
+	function, ok := ast.(*Program).statements[0].(*FunctionDef)
+	if !ok {
+		t.Fatalf("Expected function definition, got %T", ast.(*Program).statements[0])
+	}
+	assertEq(2, len(function.decorators), t)
+
+	_, err = NewParser("@memoize var x = 1;").ParseProgram()
+	if err == nil {
+		t.Fatalf("expected error was not thrown")
+	}
+}
+
+func TestParser_ParseSelect(t *testing.T) {
+	code := `
+	var ch = channel();
+	select {
+		case var msg = ch.recv() { print msg; }
+		case ch.send(1) {}
+		default {}
+	}`
+
+	_, err := NewParser(code).ParseProgram()
+	if err != nil {
+		t.Fatalf("parser.ParseProgram() error = %v", err)
+	}
+
+	for _, invalid := range []string{
+		"var ch; select { case var x = ch.send(1) {} }",
+		"var ch; select { case ch.peek() {} }",
+		"var ch; select { default {} default {} }",
+		"fun f() {} spawn f;",
+	} {
+		_, err = NewParser(invalid).ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error was not thrown for %s", invalid)
+		}
+	}
+}
+
+func TestParser_ParseAsync(t *testing.T) {
+	code := `
+	async fun f() { return await sleep(1); }
+	var g = async fun () { await f(); };
+	class A { async m() { await this.n(); } n() {} }`
+
+	_, err := NewParser(code).ParseProgram()
+	if err != nil {
+		t.Fatalf("parser.ParseProgram() error = %v", err)
+	}
+
+	for _, invalid := range []string{
+		"fun f() { await sleep(1); }",
+		"async fun f() { var g = fun () { await sleep(1); }; }",
+		"async fun* f() {}",
+		"class A { async init() {} }",
+	} {
+		_, err = NewParser(invalid).ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error was not thrown for %s", invalid)
+		}
+	}
+}
+
+func TestParser_ParseHoistedFunctionErrors(t *testing.T) {
+	for _, invalid := range []string{
+		"{ print f(); fun f() {} }",
+		"{ { f(); } fun f() {} }",
+		"{ fun f() {} fun f() {} }",
+		"{ var f = 1; fun f() {} }",
+		"{ @f fun f() {} }",
+	} {
+		_, err := NewParser(invalid).ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error was not thrown for %s", invalid)
+		}
+	}
+}
+
+func TestParser_ParseStaticMembers(t *testing.T) {
+	code := `
+	class A {
+		static count = 0;
+		static empty;
+		static create() { return A(); }
+		@memoize static cached(n) { return n; }
+		size() { return A.count; }
+	}`
+
+	program, err := NewParser(code).ParseProgram()
+	if err != nil {
+		t.Fatalf("parser.ParseProgram() error = %v", err)
+	}
+	class := program.(*Program).statements[0].(*ClassDef)
+	if len(class.staticFields) != 2 || len(class.functions) != 3 || !class.functions[1].isStatic || class.functions[2].isStatic {
+		t.Fatalf("unexpected class definition %+v", class)
+	}
+
+	for _, invalid := range []string{
+		"class A { static f() { return this; } }",
+		"class A { static X = this; }",
+		"class A { static X = 1; static X = 2; }",
+		"class A { static f() { return super.f(); } }",
+	} {
+		_, err = NewParser(invalid).ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error was not thrown for %s", invalid)
+		}
+	}
+
+	derived := "class A {} class B < A { static X = super.X; static f() { return super.f(); } }"
+	_, err = NewParser(derived).ParseProgram()
+	if err != nil {
+		t.Fatalf("parser.ParseProgram() error = %v for %s", err, derived)
+	}
+}
+
+func TestParser_ParseAccessors(t *testing.T) {
+	code := `
+	class A {
+		get size() { return 1; }
+		set size(value) {}
+		get(key) { return key; }
+		set(key, value) {}
+	}`
+
+	program, err := NewParser(code).ParseProgram()
+	if err != nil {
+		t.Fatalf("parser.ParseProgram() error = %v", err)
+	}
+	functions := program.(*Program).statements[0].(*ClassDef).functions
+	if !functions[0].isGetter || !functions[1].isSetter || functions[2].isAccessor() || functions[3].isAccessor() {
+		t.Fatalf("unexpected methods %+v", functions)
+	}
+
+	for _, invalid := range []string{
+		"class A { get x(v) {} }",
+		"class A { set x() {} }",
+		"class A { set x(...v) {} }",
+		"class A { get x() {} get x() {} }",
+		"class A { get x() {} x() {} }",
+		"class A { @memoize get x() {} }",
+	} {
+		_, err = NewParser(invalid).ParseProgram()
+		if err == nil {
+			t.Fatalf("expected error was not thrown for %s", invalid)
+		}
+	}
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Scanner struct {
@@ -46,6 +49,12 @@ func (s *Scanner) AdvanceToken() (TokenInfo, error) {
 			return newToken(EOF, "", 0, 0), nil
 		}
 
+		if cInfo.char == '.' && string(s.peekNChars(2)) == ".." {
+			_, _ = s.advanceChar()
+			_, _ = s.advanceChar()
+			return newToken(Ellipsis, "...", cInfo.line, cInfo.column), nil
+		}
+
 		tokenType, ok := singleCharTokenTypes[cInfo.char]
 		if ok {
 			return newToken(
@@ -71,7 +80,12 @@ func (s *Scanner) AdvanceToken() (TokenInfo, error) {
 				continue
 			}
 		case '"':
-			return s.scanString(cInfo), nil
+			return s.scanString(cInfo, false), nil
+		case 'r':
+			nextChar, errPeek := s.peekChar()
+			if errPeek == nil && nextChar == '"' {
+				return s.scanString(cInfo, true), nil
+			}
 		}
 
 		if unicode.IsDigit(cInfo.char) {
@@ -127,37 +141,37 @@ func (s *Scanner) scanIdentifier(cInfo charInfo) TokenInfo {
 
 func (s *Scanner) scanNumber(cInfo charInfo) TokenInfo {
 	lexeme := string(cInfo.char)
-	foundDot := false
-loop:
-	for {
-		nextChars := s.peekNChars(2)
-		switch len(nextChars) {
-		case 0:
-			break loop
-		case 1:
-			ch := nextChars[0]
-			if unicode.IsDigit(ch) {
-				lexeme += string(ch)
+
+	nextChars := s.peekNChars(2)
+	if cInfo.char == '0' && len(nextChars) == 2 {
+		isDigit, isPrefix := radixDigits[unicode.ToLower(nextChars[0])]
+		if isPrefix && isDigit(nextChars[1]) {
+			_, _ = s.advanceChar()
+			lexeme = s.scanDigits(lexeme+string(nextChars[0]), isDigit)
+			return newToken(Number, lexeme, cInfo.line, cInfo.column)
+		}
+	}
+
+	lexeme = s.scanDigits(lexeme, isDecimalDigit)
+
+	nextChars = s.peekNChars(2)
+	if len(nextChars) == 2 && nextChars[0] == '.' && isDecimalDigit(nextChars[1]) {
+		_, _ = s.advanceChar()
+		lexeme = s.scanDigits(lexeme+".", isDecimalDigit)
+	}
+
+	nextChars = s.peekNChars(3)
+	if len(nextChars) >= 2 && (nextChars[0] == 'e' || nextChars[0] == 'E') {
+		exponentStart := 1
+		if nextChars[1] == '+' || nextChars[1] == '-' {
+			exponentStart = 2
+		}
+		if len(nextChars) > exponentStart && isDecimalDigit(nextChars[exponentStart]) {
+			for _, ch := range nextChars[:exponentStart] {
 				_, _ = s.advanceChar()
-			}
-			break loop
-		case 2:
-			ch := nextChars[0]
-			if unicode.IsDigit(ch) {
 				lexeme += string(ch)
-				_, _ = s.advanceChar()
-			} else if ch == '.' && !foundDot {
-				foundDot = true
-				ch2 := nextChars[1]
-				if unicode.IsDigit(ch2) {
-					lexeme += string(ch)
-					_, _ = s.advanceChar()
-				} else {
-					break loop
-				}
-			} else {
-				break loop
 			}
+			lexeme = s.scanDigits(lexeme, isDecimalDigit)
 		}
 	}
 
@@ -169,8 +183,59 @@ loop:
 	)
 }
 
-func (s *Scanner) scanString(cInfo charInfo) TokenInfo {
+// scanDigits appends all following digits to lexeme. A '_' between two digits
+// is accepted as separator.
+func (s *Scanner) scanDigits(lexeme string, isDigit func(rune) bool) string {
+	for {
+		nextChars := s.peekNChars(2)
+		if len(nextChars) == 0 {
+			return lexeme
+		}
+		ch := nextChars[0]
+		isSeparator := ch == '_' && len(nextChars) == 2 && isDigit(nextChars[1]) &&
+			isDigit(rune(lexeme[len(lexeme)-1]))
+		if !isDigit(ch) && !isSeparator {
+			return lexeme
+		}
+		_, _ = s.advanceChar()
+		lexeme += string(ch)
+	}
+}
+
+// radixDigits maps the prefixes of hexadecimal, binary and octal literals
+// (after the leading 0) to their valid digits
+var radixDigits = map[rune]func(rune) bool{
+	'x': func(ch rune) bool {
+		return isDecimalDigit(ch) || ('a' <= unicode.ToLower(ch) && unicode.ToLower(ch) <= 'f')
+	},
+	'b': func(ch rune) bool { return ch == '0' || ch == '1' },
+	'o': func(ch rune) bool { return '0' <= ch && ch <= '7' },
+}
+
+func isDecimalDigit(ch rune) bool {
+	return '0' <= ch && ch <= '9'
+}
+
+// scanString scans regular strings "...", multi-line strings """...""" and
+// their raw variants r"..." and r"""...""" in which backslashes have no
+// special meaning. Non-raw strings may contain interpolated expressions ${...}.
+func (s *Scanner) scanString(cInfo charInfo, raw bool) TokenInfo {
 	lexeme := string(cInfo.char)
+	if raw {
+		_, _ = s.advanceChar()
+		lexeme += "\""
+	}
+	multiLine := s.peekQuotes()
+	if multiLine {
+		_, _ = s.advanceChar()
+		_, _ = s.advanceChar()
+		lexeme += "\"\""
+	}
+
+	var literal, text strings.Builder
+	var segments []stringSegment
+	var stringError error
+
 	for {
 		ci, err := s.advanceChar()
 		if err != nil {
@@ -182,17 +247,178 @@ func (s *Scanner) scanString(cInfo charInfo) TokenInfo {
 			)
 		}
 		lexeme += string(ci.char)
-		if ci.char == '"' {
-			return newToken(
-				String,
-				lexeme,
-				cInfo.line,
-				cInfo.column,
-			)
+
+		switch {
+		case ci.char == '"' && !multiLine:
+			segments = append(segments, stringSegment{text: text.String()})
+			return s.stringToken(cInfo, lexeme, literal.String(), segments, stringError)
+		case ci.char == '"' && s.peekQuotes():
+			_, _ = s.advanceChar()
+			_, _ = s.advanceChar()
+			lexeme += "\"\""
+			segments = append(segments, stringSegment{text: text.String()})
+			return s.stringToken(cInfo, lexeme, literal.String(), segments, stringError)
+		case ci.char == '\\' && !raw:
+			escaped, source, errEscape := s.scanEscapeSequence()
+			lexeme += source
+			if errEscape != nil && stringError == nil {
+				stringError = errEscape
+			}
+			literal.WriteString(escaped)
+			text.WriteString(escaped)
+		case ci.char == '$' && !raw && s.peekInterpolation():
+			_, _ = s.advanceChar()
+			source, errInterpolation := s.scanInterpolation()
+			if errInterpolation != nil {
+				return newErrorToken(
+					lexeme,
+					errInterpolation.Error(),
+					cInfo.line,
+					cInfo.column,
+				)
+			}
+			lexeme += "{" + source + "}"
+			literal.WriteString("${" + source + "}")
+			segments = append(segments,
+				stringSegment{text: text.String()},
+				stringSegment{text: source, isExpression: true})
+			text.Reset()
+		default:
+			literal.WriteRune(ci.char)
+			text.WriteRune(ci.char)
 		}
 	}
 }
 
+func (s *Scanner) stringToken(
+	cInfo charInfo,
+	lexeme string,
+	literal string,
+	segments []stringSegment,
+	stringError error) TokenInfo {
+
+	if stringError != nil {
+		return newErrorToken(
+			lexeme,
+			stringError.Error(),
+			cInfo.line,
+			cInfo.column,
+		)
+	}
+	ret := newStringToken(
+		lexeme,
+		literal,
+		cInfo.line,
+		cInfo.column,
+	)
+	if len(segments) > 1 {
+		ret.segments = segments
+	}
+	return ret
+}
+
+func (s *Scanner) peekInterpolation() bool {
+	nextChar, err := s.peekChar()
+	return err == nil && nextChar == '{'
+}
+
+// scanInterpolation is called after ${ has been read. It returns the source
+// code of the embedded expression up to the matching closing brace.
+func (s *Scanner) scanInterpolation() (string, error) {
+	source := ""
+	depth := 1
+	inString := false
+
+	for {
+		ci, err := s.advanceChar()
+		if err != nil {
+			return "", NewScannerError("Unterminated string interpolation.")
+		}
+
+		switch {
+		case inString && ci.char == '\\':
+			escaped, errEscaped := s.advanceChar()
+			if errEscaped != nil {
+				return "", NewScannerError("Unterminated string interpolation.")
+			}
+			source += string(ci.char) + string(escaped.char)
+			continue
+		case ci.char == '"':
+			inString = !inString
+		case !inString && ci.char == '{':
+			depth++
+		case !inString && ci.char == '}':
+			depth--
+			if depth == 0 {
+				return source, nil
+			}
+		}
+		source += string(ci.char)
+	}
+}
+
+// peekQuotes checks whether the next two characters are quotes
+func (s *Scanner) peekQuotes() bool {
+	nextChars := s.peekNChars(2)
+	return len(nextChars) == 2 && nextChars[0] == '"' && nextChars[1] == '"'
+}
+
+var escapedChars = map[rune]string{
+	'n':  "\n",
+	't':  "\t",
+	'r':  "\r",
+	'0':  "\x00",
+	'"':  "\"",
+	'\'': "'",
+	'\\': "\\",
+	'$':  "$",
+}
+
+// scanEscapeSequence is called after a backslash has been read. It returns the
+// escaped text and the source characters of the escape sequence.
+func (s *Scanner) scanEscapeSequence() (string, string, error) {
+	ci, err := s.advanceChar()
+	if err != nil {
+		return "", "", NewScannerError("Unterminated string.")
+	}
+	source := string(ci.char)
+
+	escaped, ok := escapedChars[ci.char]
+	if ok {
+		return escaped, source, nil
+	}
+	if ci.char != 'u' {
+		return "", source, NewScannerError("Invalid escape sequence: \\%c", ci.char)
+	}
+
+	// unicode escape \u{XXXXXX} with one to six hex digits
+	nextChar, err := s.peekChar()
+	if err != nil || nextChar != '{' {
+		return "", source, NewScannerError("Invalid unicode escape sequence: \\%s", source)
+	}
+	_, _ = s.advanceChar()
+	source += "{"
+	digits := ""
+	for {
+		nextChar, err = s.peekChar()
+		if err != nil || nextChar == '"' {
+			return "", source, NewScannerError("Invalid unicode escape sequence: \\%s", source)
+		}
+		_, _ = s.advanceChar()
+		source += string(nextChar)
+		if nextChar == '}' {
+			break
+		}
+		digits += string(nextChar)
+	}
+	codePoint, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(codePoint)) {
+		return "", source, NewScannerError("Invalid unicode escape sequence: \\%s", source)
+	}
+
+	return string(rune(codePoint)), source, nil
+}
+
 func (s *Scanner) scanSlash(cInfo charInfo) *Token {
 	nextChar, err := s.peekChar()
 
@@ -269,12 +495,15 @@ func (s *Scanner) scanEqual(cInfo charInfo) *Token {
 	var tokenType TokenType
 	var lexeme string
 	nextChar, err := s.peekChar()
-	if nextChar != '=' || err != nil {
+	if (nextChar != '=' && nextChar != '>') || err != nil {
 		tokenType = Equal
 		lexeme = string(cInfo.char)
 	} else {
 		_, _ = s.advanceChar()
 		tokenType = EqualEqual
+		if nextChar == '>' {
+			tokenType = Arrow
+		}
 		lexeme = string(cInfo.char) + string(nextChar)
 	}
 	return newToken(
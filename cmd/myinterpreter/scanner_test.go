@@ -37,3 +37,51 @@ func assertEq(expected any, actual any, t *testing.T) {
 		t.Fatalf("expected: %v, actual: %v", expected, actual)
 	}
 }
+
+func TestScanner_StringLiterals(t *testing.T) {
+	scanner := NewScanner(`"a\tb\"\u{263A}" r"\d+" """x "y"
+z"""`)
+	var literals []string
+	for {
+		token, err := scanner.AdvanceToken()
+		if err != nil || token.GetTokenType() == EOF {
+			break
+		}
+		assertEq(String, token.GetTokenType(), t)
+		literals = append(literals, token.(*Token).literal)
+	}
+
+	assertEq(3, len(literals), t)
+	assertEq("a\tb\"☺", literals[0], t)
+	assertEq(`\d+`, literals[1], t)
+	assertEq("x \"y\"\nz", literals[2], t)
+}
+
+func TestScanner_InvalidEscapeSequence(t *testing.T) {
+	scanner := NewScanner(`"\q"`)
+	token, _ := scanner.AdvanceToken()
+
+	assertEq(Error, token.GetTokenType(), t)
+}
+
+func TestScanner_NumberLiterals(t *testing.T) {
+	scanner := NewScanner("0xFF 0b1010 0o17 1_000 1.5e-3 1e21 NaN Infinity")
+	var tokens []string
+	for {
+		token, err := scanner.AdvanceToken()
+		if err != nil || token.GetTokenType() == EOF {
+			break
+		}
+		tokens = append(tokens, token.(*Token).String())
+	}
+
+	assertEq(8, len(tokens), t)
+	assertEq("NUMBER 0xFF 255.0", tokens[0], t)
+	assertEq("NUMBER 0b1010 10.0", tokens[1], t)
+	assertEq("NUMBER 0o17 15.0", tokens[2], t)
+	assertEq("NUMBER 1_000 1000.0", tokens[3], t)
+	assertEq("NUMBER 1.5e-3 0.0015", tokens[4], t)
+	assertEq("NUMBER 1e21 1e+21", tokens[5], t)
+	assertEq("IDENTIFIER NaN null", tokens[6], t)
+	assertEq("IDENTIFIER Infinity null", tokens[7], t)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// indexable is implemented by all values supporting read access by value[index]
+type indexable interface {
+	getIndex(index Value) (Value, error)
+}
+
+// indexAssignable is implemented by all values supporting value[index] = element
+type indexAssignable interface {
+	setIndex(index Value, element Value) error
+}
+
+// sliceable is implemented by all values supporting value[start:end]. Omitted
+// bounds are passed as nil.
+type sliceable interface {
+	slice(start, end Value) (Value, error)
+}
+
+// sized is implemented by all values supporting the len builtin
+type sized interface {
+	length() int
+}
+
+func valueToInt(value Value) (int, error) {
+	switch num := value.(type) {
+	case *IntValue:
+		if num.big == nil && num.small >= math.MinInt && num.small <= math.MaxInt {
+			return int(num.small), nil
+		}
+	case *NumValue:
+		if num.Value == math.Trunc(num.Value) && math.Abs(num.Value) <= 1<<53 {
+			return int(num.Value), nil
+		}
+	}
+	return 0, fmt.Errorf("expected integer but got %s", value)
+}
+
+// normalizeIndex converts index to a position within a sequence of the given
+// length. Negative indices count from the end of the sequence.
+func normalizeIndex(index Value, length int) (int, error) {
+	i, err := valueToInt(index)
+	if err != nil {
+		return 0, err
+	}
+	pos := i
+	if pos < 0 {
+		pos += length
+	}
+	if pos < 0 || pos >= length {
+		return 0, fmt.Errorf("index %d out of range for length %d", i, length)
+	}
+	return pos, nil
+}
+
+// sliceBounds converts the (optional) bounds of a slice operation to positions
+// within a sequence of the given length. Negative bounds count from the end,
+// bounds outside the sequence are clamped.
+func sliceBounds(start, end Value, length int) (int, int, error) {
+	from, err := sliceBound(start, 0, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	to, err := sliceBound(end, length, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	if to < from {
+		to = from
+	}
+	return from, to, nil
+}
+
+func sliceBound(bound Value, defaultPos int, length int) (int, error) {
+	if bound == nil {
+		return defaultPos, nil
+	}
+	pos, err := valueToInt(bound)
+	if err != nil {
+		return 0, err
+	}
+	if pos < 0 {
+		pos += length
+	}
+	return min(max(pos, 0), length), nil
+}
+
+// reprValue returns the representation of a value as element of a collection
+func reprValue(value Value) string {
+	return reprNested(value, nil)
+}
+
+// nestedValue is implemented by collections which can contain themselves, e.g.
+// after a.push(a). visiting holds the collections which are already being
+// printed or compared further up, so that cycles are not followed forever.
+type nestedValue interface {
+	repr(visiting []Value) string
+	equals(value Value, visiting []valuePair) bool
+}
+
+type valuePair struct {
+	left, right Value
+}
+
+func reprNested(value Value, visiting []Value) string {
+	switch v := value.(type) {
+	case *StringValue:
+		return strconv.Quote(v.Value)
+	case nestedValue:
+		return v.repr(visiting)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// enterNested adds value to the collections being visited. It returns false
+// if the value is visited already.
+func enterNested(value Value, visiting []Value) ([]Value, bool) {
+	if slices.Contains(visiting, value) {
+		return visiting, false
+	}
+	return append(visiting, value), true
+}
+
+func equalNested(left, right Value, visiting []valuePair) bool {
+	nested, isNested := left.(nestedValue)
+	if !isNested {
+		return left.isEqualTo(right)
+	}
+	return nested.equals(right, visiting)
+}
+
+// enterPair adds a pair of collections to the pairs being compared. It
+// returns false if the pair is compared already, then the comparison further
+// up decides about equality.
+func enterPair(left, right Value, visiting []valuePair) ([]valuePair, bool) {
+	pair := valuePair{left, right}
+	if slices.Contains(visiting, pair) {
+		return visiting, false
+	}
+	return append(visiting, pair), true
+}
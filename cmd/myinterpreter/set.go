@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SetValue is a hash set which keeps its elements in insertion order
+type SetValue struct {
+	table *valueTable
+}
+
+func NewSetValue() *SetValue {
+	return &SetValue{newValueTable()}
+}
+
+func (s *SetValue) getType() ValueType {
+	return VtSet
+}
+
+func (s *SetValue) isEqualTo(value Value) bool {
+	other, ok := value.(*SetValue)
+	return ok && s.table.size() == other.table.size() && s.isSubsetOf(1, other)
+}
+
+func (s *SetValue) isTruthy() bool {
+	return true
+}
+
+func (s *SetValue) String() string {
+	if s.table.size() == 0 {
+		return "set()"
+	}
+	var elements []string
+	for _, entry := range s.table.liveEntries() {
+		elements = append(elements, reprValue(entry.key))
+	}
+	return "{" + strings.Join(elements, ", ") + "}"
+}
+
+func (s *SetValue) length() int {
+	return s.table.size()
+}
+
+func (s *SetValue) add(depth int, element Value) error {
+	return s.table.put(depth, element, nil)
+}
+
+func (s *SetValue) has(depth int, element Value) bool {
+	entry, err := s.table.lookup(depth, element)
+	return err == nil && entry != nil
+}
+
+func (s *SetValue) isSubsetOf(depth int, other *SetValue) bool {
+	for _, entry := range s.table.liveEntries() {
+		if !other.has(depth, entry.key) {
+			return false
+		}
+	}
+	return true
+}
+
+// iterate walks through the elements of the set in insertion order
+func (s *SetValue) iterate() (valueIterator, error) {
+	var elements []Value
+	for _, entry := range s.table.liveEntries() {
+		elements = append(elements, entry.key)
+	}
+	return &listIterator{list: NewListValue(elements)}, nil
+}
+
+func (s *SetValue) getMember(name string) (Value, error) {
+	method, ok := setMethods[name]
+	if !ok {
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+	return NewBuiltinFuncValueAt(name, method.minArity, method.maxArity, func(depth int, args []Value) (Value, error) {
+		return method.fn(depth, s, args)
+	}), nil
+}
+
+// setFn creates a set from the elements of an optional iterable
+func setFn(depth int, args []Value) (Value, error) {
+	if len(args) == 0 {
+		return NewSetValue(), nil
+	}
+	return newSetFromIterable(depth, args[0])
+}
+
+func newSetFromIterable(depth int, value Value) (*SetValue, error) {
+	iterator, err := iterate(depth, value)
+	if err != nil {
+		return nil, err
+	}
+	ret := NewSetValue()
+	for {
+		hasNext, err := iterator.hasNext()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			return ret, nil
+		}
+		element, err := iterator.next()
+		if err != nil {
+			return nil, err
+		}
+		err = ret.add(depth, element)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// setMethod is a method of sets, which is called with between minArity and
+// maxArity arguments
+type setMethod struct {
+	minArity int
+	maxArity int
+	fn       func(depth int, s *SetValue, args []Value) (Value, error)
+}
+
+var setMethods = map[string]setMethod{
+	"add":          {1, variadic, setAdd},
+	"remove":       {1, 1, setRemove},
+	"has":          {1, 1, setHas},
+	"union":        {1, 1, setUnion},
+	"intersection": {1, 1, setIntersection},
+	"difference":   {1, 1, setDifference},
+	"isSubset":     {1, 1, setIsSubset},
+	"isSuperset":   {1, 1, setIsSuperset},
+}
+
+func setAdd(depth int, s *SetValue, args []Value) (Value, error) {
+	for _, arg := range args {
+		err := s.add(depth, arg)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return NewNilValue(), nil
+}
+
+func setRemove(depth int, s *SetValue, args []Value) (Value, error) {
+	removed, err := s.table.remove(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(removed), nil
+}
+
+func setHas(depth int, s *SetValue, args []Value) (Value, error) {
+	entry, err := s.table.lookup(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(entry != nil), nil
+}
+
+// otherSet converts the argument of a binary set operation to a set. Any
+// iterable value is accepted.
+func otherSet(depth int, value Value) (*SetValue, error) {
+	other, ok := value.(*SetValue)
+	if ok {
+		return other, nil
+	}
+	return newSetFromIterable(depth, value)
+}
+
+func setUnion(depth int, s *SetValue, args []Value) (Value, error) {
+	other, err := otherSet(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	ret := NewSetValue()
+	for _, entry := range append(s.table.liveEntries(), other.table.liveEntries()...) {
+		_ = ret.add(depth, entry.key) // elements are already known to be hashable
+	}
+	return ret, nil
+}
+
+func setIntersection(depth int, s *SetValue, args []Value) (Value, error) {
+	other, err := otherSet(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	ret := NewSetValue()
+	for _, entry := range s.table.liveEntries() {
+		if other.has(depth, entry.key) {
+			_ = ret.add(depth, entry.key)
+		}
+	}
+	return ret, nil
+}
+
+func setDifference(depth int, s *SetValue, args []Value) (Value, error) {
+	other, err := otherSet(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	ret := NewSetValue()
+	for _, entry := range s.table.liveEntries() {
+		if !other.has(depth, entry.key) {
+			_ = ret.add(depth, entry.key)
+		}
+	}
+	return ret, nil
+}
+
+func setIsSubset(depth int, s *SetValue, args []Value) (Value, error) {
+	other, err := otherSet(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(s.isSubsetOf(depth, other)), nil
+}
+
+func setIsSuperset(depth int, s *SetValue, args []Value) (Value, error) {
+	other, err := otherSet(depth, args[0])
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(other.isSubsetOf(depth, s)), nil
+}
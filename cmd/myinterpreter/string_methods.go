@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// All positions of string methods refer to characters (runes), not bytes.
+
+func (s *StringValue) getMember(name string) (Value, error) {
+	if name == "length" {
+		return NewIntValue(int64(s.length())), nil
+	}
+	method, ok := stringMethods[name]
+	if !ok {
+		return nil, fmt.Errorf("no member with name '%s' found", name)
+	}
+	return NewBuiltinFuncValue(name, method.minArity, method.maxArity, func(args []Value) (Value, error) {
+		return method.fn(s, args)
+	}), nil
+}
+
+func (s *StringValue) getIndex(index Value) (Value, error) {
+	characters := []rune(s.Value)
+	pos, err := normalizeIndex(index, len(characters))
+	if err != nil {
+		return nil, fmt.Errorf("string %w", err)
+	}
+	return NewStringValue(string(characters[pos])), nil
+}
+
+func (s *StringValue) slice(start, end Value) (Value, error) {
+	characters := []rune(s.Value)
+	from, to, err := sliceBounds(start, end, len(characters))
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(string(characters[from:to])), nil
+}
+
+// stringMethod is a method of strings, which is called with between minArity
+// and maxArity arguments
+type stringMethod struct {
+	minArity int
+	maxArity int
+	fn       func(s *StringValue, args []Value) (Value, error)
+}
+
+var stringMethods = map[string]stringMethod{
+	"substring":   {1, 2, stringSubstring},
+	"indexOf":     {1, 1, stringIndexOf},
+	"contains":    {1, 1, stringContains},
+	"startsWith":  {1, 1, stringStartsWith},
+	"endsWith":    {1, 1, stringEndsWith},
+	"upper":       {0, 0, stringUpper},
+	"lower":       {0, 0, stringLower},
+	"trim":        {0, 0, stringTrim},
+	"replace":     {2, 2, stringReplace},
+	"split":       {1, 1, stringSplit},
+	"repeat":      {1, 1, stringRepeat},
+	"charAt":      {1, 1, stringCharAt},
+	"codePointAt": {1, 1, stringCodePointAt},
+}
+
+// stringArgs checks that all arguments of a string method are strings
+func stringArgs(method string, args []Value) ([]string, error) {
+	var ret []string
+	for _, arg := range args {
+		str, ok := arg.(*StringValue)
+		if !ok {
+			return nil, fmt.Errorf("%s() expects string arguments but got %s", method, arg)
+		}
+		ret = append(ret, str.Value)
+	}
+	return ret, nil
+}
+
+func stringSubstring(s *StringValue, args []Value) (Value, error) {
+	if len(args) == 1 {
+		return s.slice(args[0], nil)
+	}
+	return s.slice(args[0], args[1])
+}
+
+func stringIndexOf(s *StringValue, args []Value) (Value, error) {
+	strArgs, err := stringArgs("indexOf", args)
+	if err != nil {
+		return nil, err
+	}
+	offset := strings.Index(s.Value, strArgs[0])
+	if offset == -1 {
+		return NewIntValue(-1), nil
+	}
+	return NewIntValue(int64(utf8.RuneCountInString(s.Value[:offset]))), nil
+}
+
+func stringContains(s *StringValue, args []Value) (Value, error) {
+	strArgs, err := stringArgs("contains", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(strings.Contains(s.Value, strArgs[0])), nil
+}
+
+func stringStartsWith(s *StringValue, args []Value) (Value, error) {
+	strArgs, err := stringArgs("startsWith", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(strings.HasPrefix(s.Value, strArgs[0])), nil
+}
+
+func stringEndsWith(s *StringValue, args []Value) (Value, error) {
+	strArgs, err := stringArgs("endsWith", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewBooleanValue(strings.HasSuffix(s.Value, strArgs[0])), nil
+}
+
+func stringUpper(s *StringValue, args []Value) (Value, error) {
+	return NewStringValue(strings.ToUpper(s.Value)), nil
+}
+
+func stringLower(s *StringValue, args []Value) (Value, error) {
+	return NewStringValue(strings.ToLower(s.Value)), nil
+}
+
+func stringTrim(s *StringValue, args []Value) (Value, error) {
+	return NewStringValue(strings.TrimSpace(s.Value)), nil
+}
+
+// stringReplace replaces all occurrences of its first argument
+func stringReplace(s *StringValue, args []Value) (Value, error) {
+	strArgs, err := stringArgs("replace", args)
+	if err != nil {
+		return nil, err
+	}
+	return NewStringValue(strings.ReplaceAll(s.Value, strArgs[0], strArgs[1])), nil
+}
+
+// stringSplit splits the string at each occurrence of the separator. An empty
+// separator splits the string into its characters.
+func stringSplit(s *StringValue, args []Value) (Value, error) {
+	strArgs, err := stringArgs("split", args)
+	if err != nil {
+		return nil, err
+	}
+	var parts []Value
+	for _, part := range strings.Split(s.Value, strArgs[0]) {
+		parts = append(parts, NewStringValue(part))
+	}
+	return NewListValue(parts), nil
+}
+
+func stringRepeat(s *StringValue, args []Value) (Value, error) {
+	count, err := valueToInt(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if count < 0 {
+		return nil, errors.New("repeat() count must not be negative")
+	}
+	return NewStringValue(strings.Repeat(s.Value, count)), nil
+}
+
+func stringCharAt(s *StringValue, args []Value) (Value, error) {
+	return s.getIndex(args[0])
+}
+
+func stringCodePointAt(s *StringValue, args []Value) (Value, error) {
+	characters := []rune(s.Value)
+	pos, err := normalizeIndex(args[0], len(characters))
+	if err != nil {
+		return nil, fmt.Errorf("string %w", err)
+	}
+	return NewIntValue(int64(characters[pos])), nil
+}
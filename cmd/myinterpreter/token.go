@@ -2,7 +2,7 @@ package main
 
 import (
 	"fmt"
-	"strconv"
+	"math"
 	"strings"
 )
 
@@ -13,15 +13,21 @@ const (
 	RightParen   TokenType = "RIGHT_PAREN"
 	LeftBrace    TokenType = "LEFT_BRACE"
 	RightBrace   TokenType = "RIGHT_BRACE"
+	LeftBracket  TokenType = "LEFT_BRACKET"
+	RightBracket TokenType = "RIGHT_BRACKET"
 	Plus         TokenType = "PLUS"
 	Minus        TokenType = "MINUS"
 	Star         TokenType = "STAR"
 	Slash        TokenType = "SLASH"
 	Dot          TokenType = "DOT"
+	Ellipsis     TokenType = "ELLIPSIS"
 	Comma        TokenType = "COMMA"
 	Semicolon    TokenType = "SEMICOLON"
+	Colon        TokenType = "COLON"
+	At           TokenType = "AT"
 	Equal        TokenType = "EQUAL"
 	EqualEqual   TokenType = "EQUAL_EQUAL"
+	Arrow        TokenType = "ARROW"
 	Bang         TokenType = "BANG"
 	BangEqual    TokenType = "BANG_EQUAL"
 	Less         TokenType = "LESS"
@@ -32,42 +38,62 @@ const (
 	Number       TokenType = "NUMBER"
 	Identifier   TokenType = "IDENTIFIER"
 	And          TokenType = "AND"
+	Async        TokenType = "ASYNC"
+	Await        TokenType = "AWAIT"
+	Break        TokenType = "BREAK"
 	Class        TokenType = "CLASS"
+	Continue     TokenType = "CONTINUE"
+	Defer        TokenType = "DEFER"
 	Else         TokenType = "ELSE"
 	False        TokenType = "FALSE"
 	For          TokenType = "FOR"
 	Fun          TokenType = "FUN"
 	If           TokenType = "IF"
+	In           TokenType = "IN"
 	Nil          TokenType = "NIL"
 	Or           TokenType = "OR"
 	Print        TokenType = "PRINT"
 	Return       TokenType = "RETURN"
+	Select       TokenType = "SELECT"
+	Spawn        TokenType = "SPAWN"
+	Static       TokenType = "STATIC"
 	Super        TokenType = "SUPER"
 	This         TokenType = "THIS"
 	True         TokenType = "TRUE"
 	Var          TokenType = "VAR"
 	While        TokenType = "WHILE"
+	Yield        TokenType = "YIELD"
 	Error        TokenType = "ERROR"
 	EOF          TokenType = "EOF"
 )
 
 var reservedWords = map[string]TokenType{
-	"and":    And,
-	"class":  Class,
-	"else":   Else,
-	"false":  False,
-	"for":    For,
-	"fun":    Fun,
-	"if":     If,
-	"nil":    Nil,
-	"or":     Or,
-	"print":  Print,
-	"return": Return,
-	"super":  Super,
-	"this":   This,
-	"true":   True,
-	"var":    Var,
-	"while":  While,
+	"and":      And,
+	"async":    Async,
+	"await":    Await,
+	"break":    Break,
+	"class":    Class,
+	"continue": Continue,
+	"defer":    Defer,
+	"else":     Else,
+	"false":    False,
+	"for":      For,
+	"fun":      Fun,
+	"if":       If,
+	"in":       In,
+	"nil":      Nil,
+	"or":       Or,
+	"print":    Print,
+	"return":   Return,
+	"select":   Select,
+	"spawn":    Spawn,
+	"static":   Static,
+	"super":    Super,
+	"this":     This,
+	"true":     True,
+	"var":      Var,
+	"while":    While,
+	"yield":    Yield,
 }
 
 var singleCharTokenTypes = map[rune]TokenType{
@@ -75,12 +101,16 @@ var singleCharTokenTypes = map[rune]TokenType{
 	')': RightParen,
 	'{': LeftBrace,
 	'}': RightBrace,
+	'[': LeftBracket,
+	']': RightBracket,
 	'+': Plus,
 	'-': Minus,
 	'*': Star,
 	'.': Dot,
 	',': Comma,
 	';': Semicolon,
+	':': Colon,
+	'@': At,
 }
 
 type TokenInfo interface {
@@ -92,10 +122,19 @@ type TokenInfo interface {
 type Token struct {
 	tokenType TokenType
 	lexeme    string
+	literal   string          // value of string literals after escape processing
+	segments  []stringSegment // parts of interpolated strings, nil for plain strings
 	line      int
 	column    int
 }
 
+// stringSegment is either a text or the source of an embedded expression
+// within an interpolated string
+type stringSegment struct {
+	text         string
+	isExpression bool
+}
+
 func newToken(tokenType TokenType, lexeme string, line int, column int) *Token {
 	return &Token{
 		tokenType: tokenType,
@@ -105,19 +144,29 @@ func newToken(tokenType TokenType, lexeme string, line int, column int) *Token {
 	}
 }
 
+func newStringToken(lexeme string, literal string, line int, column int) *Token {
+	return &Token{
+		tokenType: String,
+		lexeme:    lexeme,
+		literal:   literal,
+		line:      line,
+		column:    column,
+	}
+}
+
 func (t Token) String() string {
 	switch t.tokenType {
 	case String:
-		length := len(t.lexeme)
-		strValue := t.lexeme[1 : length-1]
-		return fmt.Sprintf("%s %s %s", t.tokenType, t.lexeme, strValue)
+		return fmt.Sprintf("%s %s %s", t.tokenType, t.lexeme, t.literal)
 	case Number:
-		floatValue, err := strconv.ParseFloat(t.lexeme, 64)
-		if err != nil {
-			floatValue = 0.0
+		var literalStr string
+		switch value := parseNumberLiteral(t.lexeme).(type) {
+		case *IntValue:
+			literalStr = value.String() + ".0"
+		case *NumValue:
+			literalStr = floatValueToStr(value.Value)
 		}
-		floatValueStr := floatValueToStr(floatValue)
-		return fmt.Sprintf("%s %s %s", t.tokenType, t.lexeme, floatValueStr)
+		return fmt.Sprintf("%s %s %s", t.tokenType, t.lexeme, literalStr)
 
 	default:
 		return fmt.Sprintf("%s %s null", t.tokenType, t.lexeme)
@@ -125,10 +174,11 @@ func (t Token) String() string {
 
 }
 
+// floatValueToStr formats a float literal, integral values keep a trailing .0
 func floatValueToStr(value float64) string {
-	numStr := strings.TrimRight(fmt.Sprintf("%f", value), "0")
-	if numStr[len(numStr)-1] == uint8('.') {
-		numStr = numStr + "0"
+	numStr := formatFloat(value)
+	if value == math.Trunc(value) && !math.IsInf(value, 0) && !strings.Contains(numStr, "e") {
+		numStr = numStr + ".0"
 	}
 	return numStr
 }
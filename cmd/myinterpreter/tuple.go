@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TupleValue is an immutable sequence of values
+type TupleValue struct {
+	elements []Value
+}
+
+func NewTupleValue(elements []Value) *TupleValue {
+	return &TupleValue{elements}
+}
+
+func (t *TupleValue) getType() ValueType {
+	return VtTuple
+}
+
+func (t *TupleValue) isEqualTo(value Value) bool {
+	return t.equals(value, nil)
+}
+
+// equals compares the elements of tuples, which may contain cyclic lists or
+// maps. Tuples themselves cannot be part of a cycle.
+func (t *TupleValue) equals(value Value, visiting []valuePair) bool {
+	other, ok := value.(*TupleValue)
+	if !ok || len(t.elements) != len(other.elements) {
+		return false
+	}
+	for i, element := range t.elements {
+		if !equalNested(element, other.elements[i], visiting) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *TupleValue) isTruthy() bool {
+	return true
+}
+
+func (t *TupleValue) String() string {
+	return t.repr(nil)
+}
+
+func (t *TupleValue) repr(visiting []Value) string {
+	var elements []string
+	for _, element := range t.elements {
+		elements = append(elements, reprNested(element, visiting))
+	}
+	if len(elements) == 1 {
+		return "(" + elements[0] + ",)"
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
+func (t *TupleValue) length() int {
+	return len(t.elements)
+}
+
+func (t *TupleValue) getIndex(index Value) (Value, error) {
+	pos, err := normalizeIndex(index, len(t.elements))
+	if err != nil {
+		return nil, fmt.Errorf("tuple %w", err)
+	}
+	return t.elements[pos], nil
+}
+
+func (t *TupleValue) slice(start, end Value) (Value, error) {
+	from, to, err := sliceBounds(start, end, len(t.elements))
+	if err != nil {
+		return nil, err
+	}
+	return NewTupleValue(t.elements[from:to]), nil
+}
+
+func (t *TupleValue) iterate() (valueIterator, error) {
+	return &listIterator{list: NewListValue(t.elements)}, nil
+}
+
+// unpack splits value into exactly count elements for a destructuring
+// assignment. All iterable values can be destructured, iterators of instances
+// are called at the given call depth.
+func unpack(depth int, value Value, count int) ([]Value, error) {
+	iterator, err := iterate(depth, value)
+	if err != nil {
+		return nil, fmt.Errorf("cannot destructure %s", value)
+	}
+	var values []Value
+	for len(values) <= count {
+		hasNext, err := iterator.hasNext()
+		if err != nil {
+			return nil, err
+		}
+		if !hasNext {
+			break
+		}
+		element, err := iterator.next()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, element)
+	}
+	if len(values) > count {
+		return nil, fmt.Errorf("too many values to destructure, expected %d", count)
+	}
+	if len(values) < count {
+		return nil, fmt.Errorf("not enough values to destructure, expected %d but got %d", count, len(values))
+	}
+	return values, nil
+}
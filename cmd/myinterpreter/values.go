@@ -3,13 +3,19 @@ package main
 import (
 	"errors"
 	"fmt"
-	"strings"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode/utf8"
 )
 
 type ValueType int
 
 const (
 	VtNumber ValueType = iota
+	VtInteger
 	VtBoolean
 	VtNil
 	VtString
@@ -17,6 +23,17 @@ const (
 	VtLambda
 	VtClass
 	VtInstance
+	VtRange
+	VtGenerator
+	VtList
+	VtMap
+	VtTuple
+	VtSet
+	VtBytes
+	VtTask
+	VtChannel
+	VtWaitGroup
+	VtPromise
 )
 
 type Value interface {
@@ -38,10 +55,14 @@ func (n *NumValue) getType() ValueType {
 }
 
 func (n *NumValue) isEqualTo(other Value) bool {
-	if other == nil || other.getType() != n.getType() {
+	switch otherNum := other.(type) {
+	case *NumValue:
+		return n.Value == otherNum.Value
+	case *IntValue:
+		return otherNum.isEqualTo(n)
+	default:
 		return false
 	}
-	return n.Value == other.(*NumValue).Value
 }
 
 func (n *NumValue) isTruthy() bool {
@@ -49,13 +70,70 @@ func (n *NumValue) isTruthy() bool {
 }
 
 func (n *NumValue) String() string {
-	numStr := strings.TrimRight(fmt.Sprintf("%f", n.Value), "0")
-	lastIdx := len(numStr) - 1
-	if numStr[lastIdx] == uint8('.') {
-		numStr = numStr[:lastIdx]
+	return formatFloat(n.Value)
+}
+
+// IntValue is an exact integer. Values within the range of int64 are stored
+// in small, larger values are promoted to big.
+type IntValue struct {
+	small int64
+	big   *big.Int // nil if the value fits into small
+}
+
+func NewIntValue(v int64) *IntValue {
+	return &IntValue{small: v}
+}
+
+func NewBigIntValue(v *big.Int) *IntValue {
+	if v.IsInt64() {
+		return &IntValue{small: v.Int64()}
 	}
+	return &IntValue{big: v}
+}
 
-	return numStr
+func (n *IntValue) getType() ValueType {
+	return VtInteger
+}
+
+func (n *IntValue) isEqualTo(other Value) bool {
+	switch otherNum := other.(type) {
+	case *IntValue:
+		return n.toBig().Cmp(otherNum.toBig()) == 0
+	case *NumValue:
+		if math.IsInf(otherNum.Value, 0) || otherNum.Value != math.Trunc(otherNum.Value) {
+			return false
+		}
+		otherInt, _ := big.NewFloat(otherNum.Value).Int(nil)
+		return n.toBig().Cmp(otherInt) == 0
+	default:
+		return false
+	}
+}
+
+func (n *IntValue) isTruthy() bool {
+	return n.big != nil || n.small != 0
+}
+
+func (n *IntValue) String() string {
+	if n.big != nil {
+		return n.big.String()
+	}
+	return strconv.FormatInt(n.small, 10)
+}
+
+func (n *IntValue) toBig() *big.Int {
+	if n.big != nil {
+		return n.big
+	}
+	return big.NewInt(n.small)
+}
+
+func (n *IntValue) toFloat() float64 {
+	if n.big != nil {
+		ret, _ := new(big.Float).SetInt(n.big).Float64()
+		return ret
+	}
+	return float64(n.small)
 }
 
 type BooleanValue struct {
@@ -141,17 +219,96 @@ func (s *StringValue) String() string {
 	return s.Value
 }
 
+func (s *StringValue) length() int {
+	return utf8.RuneCountInString(s.Value)
+}
+
+func (s *StringValue) iterate() (valueIterator, error) {
+	return &stringIterator{characters: []rune(s.Value)}, nil
+}
+
+// maxCallDepth limits the nesting of function calls, so that runaway recursion
+// is reported as a runtime error instead of crashing the interpreter
+var maxCallDepth = 10000
+
+func SetMaxCallDepth(depth int) {
+	maxCallDepth = depth
+}
+
 type callable interface {
 	call(args []Value) (Value, error)
 }
 
+type namedArg struct {
+	name  string
+	value Value
+}
+
+// namedArgsCallable is implemented by callables which accept named arguments
+type namedArgsCallable interface {
+	callNamed(args []Value, named []namedArg) (Value, error)
+}
+
+func callWithNamed(fn callable, args []Value, named []namedArg) (Value, error) {
+	if len(named) == 0 {
+		return fn.call(args)
+	}
+	namedFn, ok := fn.(namedArgsCallable)
+	if !ok {
+		return nil, fmt.Errorf("%s does not accept named arguments", fn)
+	}
+	return namedFn.callNamed(args, named)
+}
+
+// depthCallable is implemented by callables which can run at a given call
+// depth, so that calls made on behalf of a caller count towards its depth
+type depthCallable interface {
+	callAt(depth int, args []Value, named []namedArg) (Value, error)
+}
+
+// callAt calls fn at the given call depth if it supports it
+func callAt(fn callable, depth int, args []Value, named []namedArg) (Value, error) {
+	if depthFn, ok := fn.(depthCallable); ok {
+		return depthFn.callAt(depth, args, named)
+	}
+	return callWithNamed(fn, args, named)
+}
+
+// memberAccessor is implemented by all values whose members can be accessed
+// with the dot operator
+type memberAccessor interface {
+	getMember(name string) (Value, error)
+}
+
+// propertySetter is implemented by all values whose properties can be
+// assigned with the dot operator
+type propertySetter interface {
+	memberAccessor
+	setProperty(name string, value Value) error
+}
+
+// variadic is the maximum arity of builtin functions which accept any number
+// of arguments
+const variadic = -1
+
 type BuiltinFuncValue struct {
-	name string
-	fn   func(args []Value) (Value, error)
+	name     string
+	minArity int
+	maxArity int
+	fn       func(depth int, args []Value) (Value, error)
+}
+
+func NewBuiltinFuncValue(name string, minArity, maxArity int, f func(args []Value) (Value, error)) *BuiltinFuncValue {
+	return NewBuiltinFuncValueAt(name, minArity, maxArity, func(_ int, args []Value) (Value, error) {
+		return f(args)
+	})
 }
 
-func NewBuiltinFuncValue(name string, f func(args []Value) (Value, error)) *BuiltinFuncValue {
-	return &BuiltinFuncValue{name, f}
+// NewBuiltinFuncValueAt creates a builtin function which receives the call
+// depth it is called at. Builtins calling back into user code use it to keep
+// counting towards the depth of their caller.
+func NewBuiltinFuncValueAt(name string, minArity, maxArity int, f func(depth int, args []Value) (Value, error)) *BuiltinFuncValue {
+	return &BuiltinFuncValue{name, minArity, maxArity, f}
 }
 
 func (b *BuiltinFuncValue) getType() ValueType {
@@ -174,19 +331,51 @@ func (b *BuiltinFuncValue) String() string {
 	return fmt.Sprintf("<builtin-function %s<", b.name)
 }
 
+// call checks the number of arguments against the declared arity before the
+// builtin runs
 func (b *BuiltinFuncValue) call(args []Value) (Value, error) {
-	return b.fn(args)
+	return b.callAt(1, args, nil)
+}
+
+func (b *BuiltinFuncValue) callAt(depth int, args []Value, named []namedArg) (Value, error) {
+	if len(named) > 0 {
+		return nil, fmt.Errorf("%s does not accept named arguments", b)
+	}
+	if len(args) < b.minArity || (b.maxArity != variadic && len(args) > b.maxArity) {
+		return nil, fmt.Errorf("%s() expects %s but got %d", b.name, b.describeArity(), len(args))
+	}
+	return b.fn(depth, args)
+}
+
+func (b *BuiltinFuncValue) describeArity() string {
+	plural := func(count int) string {
+		if count == 1 {
+			return "1 argument"
+		}
+		return fmt.Sprintf("%d arguments", count)
+	}
+	switch {
+	case b.maxArity == variadic:
+		return "at least " + plural(b.minArity)
+	case b.minArity == b.maxArity:
+		return plural(b.minArity)
+	default:
+		return fmt.Sprintf("%d to %d arguments", b.minArity, b.maxArity)
+	}
 }
 
 type LambdaValue struct {
 	name          string
 	isConstructor bool
-	parameters    []string
+	isGenerator   bool
+	isAsync       bool
+	parameters    []Parameter
 	body          Block
 	env           Environment
+	decorators    []callable // of a method, applied to the bound method
 }
 
-func NewLambdaValue(name string, parameters []string, body Block, env Environment) *LambdaValue {
+func NewLambdaValue(name string, parameters []Parameter, body Block, env Environment) *LambdaValue {
 	return &LambdaValue{
 		name:       name,
 		parameters: parameters,
@@ -204,6 +393,8 @@ func (l *LambdaValue) bind(instance *InstanceValue, class *ClassValue) *LambdaVa
 	return &LambdaValue{
 		name:          l.name,
 		isConstructor: l.isConstructor,
+		isGenerator:   l.isGenerator,
+		isAsync:       l.isAsync,
 		parameters:    l.parameters,
 		body:          l.body,
 		env:           *boundEnv,
@@ -227,36 +418,133 @@ func (l *LambdaValue) String() string {
 }
 
 func (l *LambdaValue) call(args []Value) (Value, error) {
-	if len(args) != len(l.parameters) {
-		return nil, fmt.Errorf("expected %d args, got %d", len(l.parameters), len(args))
-	}
+	return l.invoke(1, args, nil)
+}
 
-	callEnv := NewEnvironment(&l.env)
-	for i, param := range l.parameters {
-		callEnv.Set(param, args[i])
+func (l *LambdaValue) callNamed(args []Value, named []namedArg) (Value, error) {
+	return l.invoke(1, args, named)
+}
+
+func (l *LambdaValue) callAt(depth int, args []Value, named []namedArg) (Value, error) {
+	return l.invoke(depth, args, named)
+}
+
+// invoke runs the function at the given call depth. Tail calls requested by
+// the body are executed in a loop instead of nesting them.
+func (l *LambdaValue) invoke(depth int, args []Value, named []namedArg) (Value, error) {
+	if depth > maxCallDepth {
+		return nil, fmt.Errorf("stack overflow (maximum call depth %d exceeded)", maxCallDepth)
 	}
 
-	interpreter := NewInterpreter(callEnv)
+	for {
+		callEnv := NewEnvironment(&l.env)
+		err := l.bindArguments(depth, callEnv, args, named)
+		if err != nil {
+			return nil, err
+		}
+
+		if l.isGenerator {
+			return NewGeneratorValue(l, callEnv, depth), nil
+		}
+		if l.isAsync {
+			return startAsync(l, callEnv, depth), nil
+		}
+
+		interpreter := NewInterpreter(callEnv)
+
+		interpreter.callDepth = depth
+		interpreter.lambdaEvalActive = true
+		interpreter.controlFlow = controlFlow{}
+
+		interpreter.visitBlock(&l.body)
+		interpreter.runDeferred()
+
+		interpreter.lambdaEvalActive = false
+		interpreter.controlFlow = controlFlow{}
+
+		tailCall := interpreter.tailCall
+		if tailCall != nil && interpreter.lastError == nil {
+			next, isLambda := tailCall.fn.(*LambdaValue)
+			if !isLambda {
+				return interpreter.call(tailCall.fn, tailCall.args, tailCall.named)
+			}
+			l, args, named = next, tailCall.args, tailCall.named
+			continue
+		}
 
-	interpreter.lambdaEvalActive = true
-	interpreter.returnOccurred = false
+		if interpreter.lastError == nil && l.isConstructor {
+			interpreter.lastResult, interpreter.lastError = l.env.Get("this")
+		}
 
-	interpreter.visitBlock(&l.body)
+		return interpreter.lastResult, interpreter.lastError
+	}
+}
 
-	interpreter.lambdaEvalActive = false
-	interpreter.returnOccurred = false
+// bindArguments defines the parameters in the environment of a call. Missing
+// arguments are replaced by the default values of their parameters, which are
+// evaluated in the call environment at the call depth. Surplus positional
+// arguments are collected by the rest parameter.
+func (l *LambdaValue) bindArguments(depth int, callEnv *Environment, args []Value, named []namedArg) error {
+	parameters := l.parameters
+	if len(parameters) > 0 && parameters[len(parameters)-1].isRest {
+		parameters = parameters[:len(parameters)-1]
+		var rest []Value
+		if len(args) > len(parameters) {
+			rest = slices.Clone(args[len(parameters):])
+			args = args[:len(parameters)]
+		}
+		callEnv.Set(l.parameters[len(parameters)].name, NewListValue(rest))
+	}
 
-	if interpreter.lastError == nil && l.isConstructor {
-		interpreter.lastResult, interpreter.lastError = l.env.Get("this")
+	if len(args) > len(parameters) {
+		return fmt.Errorf("expected %d args, got %d", len(parameters), len(args))
+	}
+	bound := make([]Value, len(parameters))
+	copy(bound, args)
+	for _, arg := range named {
+		pos := slices.IndexFunc(parameters, func(param Parameter) bool {
+			return param.name == arg.name
+		})
+		if pos == -1 {
+			return fmt.Errorf("%s has no parameter named %s", l, arg.name)
+		}
+		if bound[pos] != nil {
+			return fmt.Errorf("%s got multiple values for parameter %s", l, arg.name)
+		}
+		bound[pos] = arg.value
 	}
 
-	return interpreter.lastResult, interpreter.lastError
+	for i, param := range parameters {
+		value := bound[i]
+		if value == nil {
+			if param.defaultValue == nil {
+				// parameters with default values are always last
+				if len(named) == 0 && parameters[len(parameters)-1].defaultValue == nil {
+					return fmt.Errorf("expected %d args, got %d", len(parameters), len(args))
+				}
+				return fmt.Errorf("%s is missing a value for parameter %s", l, param.name)
+			}
+			interpreter := NewInterpreter(callEnv)
+			interpreter.callDepth = depth
+			var err error
+			value, err = interpreter.evalAst(param.defaultValue)
+			if err != nil {
+				return err
+			}
+		}
+		callEnv.Set(param.name, value)
+	}
+	return nil
 }
 
 type ClassValue struct {
 	name    string
 	super   *ClassValue
 	methods []LambdaValue
+	getters map[string]*LambdaValue
+	setters map[string]*LambdaValue
+	statics map[string]Value // static methods and fields
+	mu      sync.RWMutex     // guards statics
 }
 
 func NewClassValue(name string, super *ClassValue, methods []LambdaValue) *ClassValue {
@@ -264,6 +552,9 @@ func NewClassValue(name string, super *ClassValue, methods []LambdaValue) *Class
 		name:    name,
 		super:   super,
 		methods: methods,
+		getters: make(map[string]*LambdaValue),
+		setters: make(map[string]*LambdaValue),
+		statics: make(map[string]Value),
 	}
 }
 
@@ -287,11 +578,47 @@ func (c *ClassValue) String() string {
 	return c.name
 }
 
+// getMember looks up a static member in the class and its super classes
+func (c *ClassValue) getMember(name string) (Value, error) {
+	for class := c; class != nil; class = class.super {
+		class.mu.RLock()
+		value, ok := class.statics[name]
+		class.mu.RUnlock()
+		if ok {
+			return value, nil
+		}
+	}
+	return nil, fmt.Errorf("no static member with name '%s' found in class %s", name, c.name)
+}
+
+// setProperty sets a static field of the class. Fields of super classes are
+// shadowed, not changed.
+func (c *ClassValue) setProperty(name string, value Value) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.statics[name] = value
+	return nil
+}
+
 func (c *ClassValue) call(args []Value) (Value, error) {
+	return c.instantiate(1, args, nil)
+}
+
+func (c *ClassValue) callNamed(args []Value, named []namedArg) (Value, error) {
+	return c.instantiate(1, args, named)
+}
+
+func (c *ClassValue) callAt(depth int, args []Value, named []namedArg) (Value, error) {
+	return c.instantiate(depth, args, named)
+}
+
+// instantiate creates a new instance and runs its constructor at the given
+// call depth
+func (c *ClassValue) instantiate(depth int, args []Value, named []namedArg) (Value, error) {
 	ret := NewInstanceValue(c)
-	initMethod, err := ret.getMethod("init")
+	initMethod, err := ret.getMethod(depth, "init")
 	if err == nil {
-		_, errConstructor := initMethod.call(args)
+		_, errConstructor := callAt(initMethod, depth, args, named)
 		if errConstructor != nil {
 			return nil, errConstructor
 		}
@@ -299,6 +626,27 @@ func (c *ClassValue) call(args []Value) (Value, error) {
 	return ret, nil
 }
 
+// findMethod looks up a method in the class and its super classes. It returns
+// the method together with the class defining it.
+func (c *ClassValue) findMethod(name string) (*LambdaValue, *ClassValue, error) {
+	class := c
+	for {
+		method, err := class.getMethod(name)
+		if err == nil {
+			return method, class, nil
+		}
+		if class.super == nil {
+			return nil, nil, err
+		}
+		class = class.super
+	}
+}
+
+func (c *ClassValue) hasMethod(name string) bool {
+	_, err := c.getMethod(name)
+	return err == nil
+}
+
 func (c *ClassValue) getMethod(name string) (*LambdaValue, error) {
 	search := c.name + "::" + name
 	for _, method := range c.methods {
@@ -312,12 +660,16 @@ func (c *ClassValue) getMethod(name string) (*LambdaValue, error) {
 type InstanceValue struct {
 	class      *ClassValue
 	properties map[string]Value
+	decorated  map[string]callable // decorated methods bound to this instance
+	mu         *sync.RWMutex       // guards properties and decorated
 }
 
 func NewInstanceValue(class *ClassValue) *InstanceValue {
 	return &InstanceValue{
 		class:      class,
 		properties: make(map[string]Value),
+		decorated:  make(map[string]callable),
+		mu:         &sync.RWMutex{},
 	}
 }
 
@@ -325,6 +677,8 @@ func (i *InstanceValue) super(super *ClassValue) *InstanceValue {
 	return &InstanceValue{
 		class:      super,
 		properties: i.properties,
+		decorated:  i.decorated,
+		mu:         i.mu,
 	}
 }
 
@@ -332,16 +686,11 @@ func (i *InstanceValue) getType() ValueType {
 	return VtInstance
 }
 
+// isEqualTo compares instances by identity. == additionally uses the equals()
+// method of their class, see valuesEqual.
 func (i *InstanceValue) isEqualTo(value Value) bool {
 	other, ok := value.(*InstanceValue)
-	if !ok {
-		return false
-	}
-	if !i.class.isEqualTo(other.class) {
-		return false
-	}
-
-	return true
+	return ok && i == other
 }
 
 func (i *InstanceValue) isTruthy() bool {
@@ -353,19 +702,47 @@ func (i *InstanceValue) String() string {
 }
 
 func (i *InstanceValue) getMember(name string) (Value, error) {
+	return i.getMemberAt(1, name)
+}
+
+// getMemberAt returns a property, the value of a getter, which is called at
+// the given call depth, or a method
+func (i *InstanceValue) getMemberAt(depth int, name string) (Value, error) {
 	property, errProp := i.getProperty(name)
 	if errProp == nil {
 		return property, nil
 	}
-	method, errMethod := i.getMethod(name)
-	if errMethod == nil {
-		return method, nil
+	// members of a class override those of its super classes, a getter may
+	// be combined with a setter of a super class
+	writeOnly := false
+	for class := i.class; class != nil; class = class.super {
+		if getter, ok := class.getters[name]; ok {
+			return getter.bind(i, class).invoke(depth, nil, nil)
+		}
+		if class.hasMethod(name) {
+			if writeOnly {
+				break
+			}
+			method, err := i.getMethod(depth, name)
+			if err != nil {
+				return nil, err
+			}
+			return method.(Value), nil
+		}
+		if _, ok := class.setters[name]; ok {
+			writeOnly = true
+		}
+	}
+	if writeOnly {
+		return nil, fmt.Errorf("property %s is write-only", name)
 	}
 	return nil, fmt.Errorf("no member with name '%s' found", name)
 }
 
 func (i *InstanceValue) getProperty(name string) (Value, error) {
+	i.mu.RLock()
 	value, ok := i.properties[name]
+	i.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("property %s not found", name)
 	}
@@ -373,20 +750,143 @@ func (i *InstanceValue) getProperty(name string) (Value, error) {
 }
 
 func (i *InstanceValue) setProperty(name string, value Value) error {
+	return i.setPropertyAt(1, name, value)
+}
+
+// setPropertyAt calls the setter of the property at the given call depth if
+// there is one. Properties with only a getter are read-only. Like in
+// getMemberAt, methods override accessors of super classes.
+func (i *InstanceValue) setPropertyAt(depth int, name string, value Value) error {
+	readOnly := false
+	for class := i.class; class != nil && !class.hasMethod(name); class = class.super {
+		if setter, ok := class.setters[name]; ok {
+			_, err := setter.bind(i, class).invoke(depth, []Value{value}, nil)
+			return err
+		}
+		if _, ok := class.getters[name]; ok {
+			readOnly = true
+		}
+	}
+	if readOnly {
+		return fmt.Errorf("property %s is read-only", name)
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	i.properties[name] = value
 	return nil
 }
 
-func (i *InstanceValue) getMethod(name string) (*LambdaValue, error) {
-	class := i.class
-	for {
-		method, err := class.getMethod(name)
-		if err == nil {
-			return method.bind(i, class), nil
-		}
-		if class.super == nil {
+func (i *InstanceValue) iterate() (valueIterator, error) {
+	return i.iterateAt(1)
+}
+
+// iterateAt creates an iterator whose methods are called at the given call
+// depth
+func (i *InstanceValue) iterateAt(depth int) (valueIterator, error) {
+	method, err := i.getMethod(depth, "iterator")
+	if err != nil {
+		return nil, fmt.Errorf("instance of class %s is not iterable", i.class.name)
+	}
+	value, err := callAt(method, depth, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	iter, ok := value.(*InstanceValue)
+	if !ok {
+		return nil, fmt.Errorf("iterator() must return an instance but returned %s", value)
+	}
+	return &instanceIterator{iter, depth}, nil
+}
+
+// getMethod returns the method bound to the instance. Decorators of the
+// method receive the bound method, so that this can be used in the original
+// method. They are applied once per instance at the given call depth.
+func (i *InstanceValue) getMethod(depth int, name string) (callable, error) {
+	method, class, err := i.class.findMethod(name)
+	if err != nil {
+		return nil, err
+	}
+	bound := method.bind(i, class)
+	if len(method.decorators) == 0 {
+		return bound, nil
+	}
+
+	i.mu.RLock()
+	ret, ok := i.decorated[method.name]
+	i.mu.RUnlock()
+	if ok {
+		return ret, nil
+	}
+	value, err := decorate(depth, bound, method.decorators)
+	if err != nil {
+		return nil, err
+	}
+	ret, ok = value.(callable)
+	if !ok {
+		return nil, fmt.Errorf("decorated method %s is not callable but %s", name, value)
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if existing, decorated := i.decorated[method.name]; decorated {
+		return existing, nil // decorated concurrently by another task
+	}
+	i.decorated[method.name] = ret
+	return ret, nil
+}
+
+// decorate passes fn through the decorators, starting with the last one. The
+// decorators are called at the given call depth.
+func decorate(depth int, fn Value, decorators []callable) (Value, error) {
+	ret := fn
+	for i := len(decorators) - 1; i >= 0; i-- {
+		var err error
+		ret, err = callAt(decorators[i], depth, []Value{ret}, nil)
+		if err != nil {
 			return nil, err
 		}
-		class = class.super
 	}
+	return ret, nil
+}
+
+type RangeValue struct {
+	start    float64
+	stop     float64
+	step     float64
+	integral bool // all bounds are integers, so the range yields integers
+}
+
+func NewRangeValue(start, stop, step float64, integral bool) *RangeValue {
+	return &RangeValue{start, stop, step, integral}
+}
+
+func (r *RangeValue) getType() ValueType {
+	return VtRange
+}
+
+func (r *RangeValue) isEqualTo(value Value) bool {
+	other, ok := value.(*RangeValue)
+	if !ok {
+		return false
+	}
+	return r.start == other.start && r.stop == other.stop && r.step == other.step
+}
+
+func (r *RangeValue) isTruthy() bool {
+	return true
+}
+
+func (r *RangeValue) String() string {
+	return fmt.Sprintf("range(%s, %s, %s)", r.bound(r.start), r.bound(r.stop), r.bound(r.step))
+}
+
+func (r *RangeValue) bound(value float64) Value {
+	if r.integral {
+		return NewIntValue(int64(value))
+	}
+	return NewNumValue(value)
+}
+
+func (r *RangeValue) iterate() (valueIterator, error) {
+	return &rangeIterator{rangeValue: r, current: r.start}, nil
 }
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"slices"
 )
 
 type varInfo struct {
@@ -19,18 +20,31 @@ func newVarInfo(parent *varInfo) *varInfo {
 	}
 }
 
+// hoisted marks functions of a block whose declaration has not been reached
+// yet. They can only be used within the bodies of nested functions, which are
+// not called before the declaration has been executed.
+const hoisted = 2
+
 func (v *varInfo) getLevel(name string) (int, error) {
+	return v.resolveName(name, false)
+}
+
+// resolveName finds the scope level of a name. withinFunction tells whether
+// the name is used in a function nested in the scope.
+func (v *varInfo) resolveName(name string, withinFunction bool) (int, error) {
 	level, ok := v.vars[name]
 	if ok {
-		if level == 1 {
+		if level == 1 || level == hoisted && withinFunction {
 			return 0, nil
+		} else if level == hoisted {
+			return -1, fmt.Errorf("function %s is used before its declaration", name)
 		} else {
 			return -1, fmt.Errorf("variable %s is not finally declared", name)
 		}
 	}
 	var err error
 	if v.parent != nil {
-		level, err = v.parent.getLevel(name)
+		level, err = v.parent.resolveName(name, withinFunction || v.isParameterInfo)
 		if err != nil || level == -1 {
 			return -1, err
 		}
@@ -48,6 +62,23 @@ func (v *varInfo) addName(name string) error {
 	return nil
 }
 
+// hoistFunctions declares the functions of a block before its statements are
+// resolved, so that they can refer to each other
+func (v *varInfo) hoistFunctions(statements []Statement) error {
+	for _, stmt := range statements {
+		function, ok := stmt.(*FunctionDef)
+		if !ok {
+			continue
+		}
+		_, exists := v.vars[function.name]
+		if exists {
+			return fmt.Errorf("variable %s already defined", function.name)
+		}
+		v.vars[function.name] = hoisted
+	}
+	return nil
+}
+
 func (v *varInfo) startVarDecl(name string) error {
 	_, exists := v.vars[name]
 	if !exists {
@@ -67,7 +98,11 @@ type VariableResolver struct {
 	withinMethod            bool
 	withinConstructor       bool
 	withinDerivedClass      bool
+	withinGenerator         bool
+	withinAsync             bool
+	withinStatic            bool
 	identifierIsPathSegment bool
+	loopLabels              []string // labels of the enclosing loops, empty for unlabeled loops
 }
 
 func NewVariableResolver() *VariableResolver {
@@ -87,41 +122,66 @@ func (v *VariableResolver) visitProgram(program *Program) {
 
 func (v *VariableResolver) visitBlock(block *Block) {
 	v.varInfo = newVarInfo(v.varInfo)
+	v.err = v.varInfo.hoistFunctions(block.statements)
 	for _, stmt := range block.statements {
-		stmt.accept(v)
 		if v.err != nil {
 			break
 		}
+		stmt.accept(v)
 	}
 	v.varInfo = v.varInfo.parent
 }
 
 func (v *VariableResolver) visitVarDecl(varDecl *VarDecl) {
-	err := v.varInfo.startVarDecl(varDecl.name)
+	v.err = v.startVarDecl(varDecl.name, varDecl.expression)
+	if v.err != nil {
+		return
+	}
+	varDecl.expression.accept(v)
+	v.varInfo.endVarDecl(varDecl.name)
+}
+
+func (v *VariableResolver) visitDestructuringDecl(decl *DestructuringDecl) {
+	names := decl.pattern.names()
+	for i, name := range names {
+		if slices.Contains(names[:i], name) {
+			v.err = fmt.Errorf("variable %s is bound more than once", name)
+			return
+		}
+		v.err = v.startVarDecl(name, nil)
+		if v.err != nil {
+			return
+		}
+	}
+	decl.expression.accept(v)
+	for _, name := range names {
+		v.varInfo.endVarDecl(name)
+	}
+}
+
+func (v *VariableResolver) startVarDecl(name string, initializer AST) error {
+	err := v.varInfo.startVarDecl(name)
 	if err != nil {
 		// Is it a self definition like var a = a; ?
-		idExpr, ok := varDecl.expression.(*IdentifierExpr)
-		isSelfDefinition := ok && idExpr.name == varDecl.name
+		idExpr, ok := initializer.(*IdentifierExpr)
+		isSelfDefinition := ok && idExpr.name == name
 
 		// Is it a redeclaration at global scope?
 		isGlobalScope := v.varInfo.parent == nil
 
 		if !isSelfDefinition && !isGlobalScope {
-			v.err = err
-			return
+			return err
 		}
 	}
 	// Check if a parameter of the same name exists:
 	parameterInfo := v.varInfo.parent
 	if parameterInfo != nil && parameterInfo.isParameterInfo {
-		level, errLevel := parameterInfo.getLevel(varDecl.name)
+		level, errLevel := parameterInfo.getLevel(name)
 		if level == 0 && errLevel == nil {
-			v.err = fmt.Errorf("variable %s is already declared as parameter", varDecl.name)
-			return
+			return fmt.Errorf("variable %s is already declared as parameter", name)
 		}
 	}
-	varDecl.expression.accept(v)
-	v.varInfo.endVarDecl(varDecl.name)
+	return nil
 }
 
 func (v *VariableResolver) visitPrint(printStmt *PrintStatement) {
@@ -134,12 +194,60 @@ func (v *VariableResolver) visitReturnStmt(returnStmt *ReturnStatement) {
 		return
 	}
 	if returnStmt.expression != nil {
-		if !v.withinConstructor {
-			returnStmt.expression.accept(v)
-		} else {
+		if v.withinConstructor {
 			v.err = fmt.Errorf("return statement in constructor must not return a value")
+		} else if v.withinGenerator {
+			v.err = fmt.Errorf("return statement in generator must not return a value")
+		} else {
+			returnStmt.expression.accept(v)
+		}
+	}
+}
+
+func (v *VariableResolver) visitYieldStmt(yieldStmt *YieldStatement) {
+	if !v.withinGenerator {
+		v.err = fmt.Errorf("yield statement is only allowed in generator functions")
+		return
+	}
+	yieldStmt.expression.accept(v)
+}
+
+func (v *VariableResolver) visitDeferStmt(deferStmt *DeferStatement) {
+	if !v.inFunctionScope() {
+		v.err = fmt.Errorf("defer statement is only allowed in function scope")
+		return
+	}
+	deferStmt.call.accept(v)
+}
+
+func (v *VariableResolver) visitSelectStmt(selectStmt *SelectStatement) {
+	for _, selectCase := range selectStmt.cases {
+		selectCase.channel.accept(v)
+		if v.err != nil {
+			return
+		}
+		if selectCase.value != nil {
+			selectCase.value.accept(v)
+			if v.err != nil {
+				return
+			}
+		}
+
+		v.varInfo = newVarInfo(v.varInfo)
+		if selectCase.variable != "" {
+			v.err = v.varInfo.addName(selectCase.variable)
+		}
+		if v.err == nil {
+			selectCase.body.accept(v)
+		}
+		v.varInfo = v.varInfo.parent
+		if v.err != nil {
+			return
 		}
 	}
+	if selectStmt.defaultCase != nil {
+		selectStmt.defaultCase.accept(v)
+	}
 }
 
 func (v *VariableResolver) visitExprStmt(exprStmt *ExpressionStatement) {
@@ -165,10 +273,20 @@ func (v *VariableResolver) visitWhileStmt(whileStmt *WhileStatement) {
 	if v.err != nil {
 		return
 	}
+	v.err = v.enterLoop(whileStmt.label)
+	if v.err != nil {
+		return
+	}
+	defer v.leaveLoop()
 	whileStmt.statement.accept(v)
 }
 
 func (v *VariableResolver) visitForStmt(f *ForStatement) {
+	v.err = v.enterLoop(f.label)
+	if v.err != nil {
+		return
+	}
+	defer v.leaveLoop()
 
 	v.varInfo = newVarInfo(v.varInfo)
 	defer func() {
@@ -196,6 +314,69 @@ func (v *VariableResolver) visitForStmt(f *ForStatement) {
 	f.statement.accept(v)
 }
 
+func (v *VariableResolver) visitForInStmt(f *ForInStatement) {
+	f.iterable.accept(v)
+	if v.err != nil {
+		return
+	}
+
+	v.err = v.enterLoop(f.label)
+	if v.err != nil {
+		return
+	}
+	defer v.leaveLoop()
+
+	v.varInfo = newVarInfo(v.varInfo)
+	defer func() {
+		v.varInfo = v.varInfo.parent
+	}()
+
+	v.err = v.varInfo.addName(f.variable)
+	if v.err != nil {
+		return
+	}
+	f.statement.accept(v)
+}
+
+func (v *VariableResolver) visitBreakStmt(b *BreakStatement) {
+	v.err = v.checkJumpTarget("break", b.label)
+}
+
+func (v *VariableResolver) visitContinueStmt(c *ContinueStatement) {
+	v.err = v.checkJumpTarget("continue", c.label)
+}
+
+func (v *VariableResolver) enterLoop(label string) error {
+	if label != "" {
+		for _, enclosing := range v.loopLabels {
+			if enclosing == label {
+				return fmt.Errorf("label %s is already used by an enclosing loop", label)
+			}
+		}
+	}
+	v.loopLabels = append(v.loopLabels, label)
+	return nil
+}
+
+func (v *VariableResolver) leaveLoop() {
+	v.loopLabels = v.loopLabels[:len(v.loopLabels)-1]
+}
+
+func (v *VariableResolver) checkJumpTarget(keyword string, label string) error {
+	if len(v.loopLabels) == 0 {
+		return fmt.Errorf("%s statement is only allowed within a loop", keyword)
+	}
+	if label == "" {
+		return nil
+	}
+	for _, enclosing := range v.loopLabels {
+		if enclosing == label {
+			return nil
+		}
+	}
+	return fmt.Errorf("label %s does not refer to an enclosing loop", label)
+}
+
 func (v *VariableResolver) visitClassDef(c *ClassDef) {
 	if c.superClass != "" {
 		level, err := v.varInfo.getLevel(c.superClass)
@@ -208,33 +389,154 @@ func (v *VariableResolver) visitClassDef(c *ClassDef) {
 	if v.err != nil {
 		return
 	}
+	v.err = checkAccessors(c.functions)
+	if v.err != nil {
+		return
+	}
 	v.varInfo = newVarInfo(v.varInfo)
 	defer func() {
 		v.varInfo = v.varInfo.parent
 	}()
 	for _, fn := range c.functions {
-		v.withinMethod = true
-		v.withinConstructor = fn.name == "init"
+		v.resolveDecorators(&fn)
+		if v.err != nil {
+			return
+		}
+		if fn.name == "init" && fn.isAsync {
+			v.err = fmt.Errorf("constructor must not be async")
+			return
+		}
+		if fn.isStatic {
+			v.withinStatic = true
+		} else {
+			v.withinMethod = true
+			v.withinConstructor = fn.name == "init"
+		}
 		v.withinDerivedClass = c.superClass != ""
 		fn.accept(v)
 		v.withinMethod = false
 		v.withinConstructor = false
 		v.withinDerivedClass = false
+		v.withinStatic = false
+		if v.err != nil {
+			return
+		}
+	}
+
+	// static fields are initialized after the class has been defined
+	v.withinStatic = true
+	v.withinDerivedClass = c.superClass != ""
+	defer func() {
+		v.withinStatic = false
+		v.withinDerivedClass = false
+	}()
+	for i, field := range c.staticFields {
+		if slices.ContainsFunc(c.staticFields[:i], func(other VarDecl) bool { return other.name == field.name }) {
+			v.err = fmt.Errorf("static field %s already defined", field.name)
+			return
+		}
+		field.expression.accept(v)
 		if v.err != nil {
 			return
 		}
 	}
 }
 
+// checkAccessors makes sure that there is at most one getter and one setter
+// for a property and that no method has the same name
+func checkAccessors(functions []FunctionDef) error {
+	methods := make(map[string]bool)
+	for _, fn := range functions {
+		if !fn.isStatic && !fn.isAccessor() {
+			methods[fn.name] = true
+		}
+	}
+	accessors := make(map[string]bool)
+	for _, fn := range functions {
+		if !fn.isAccessor() {
+			continue
+		}
+		kind := "getter"
+		if fn.isSetter {
+			kind = "setter"
+		}
+		if methods[fn.name] {
+			return fmt.Errorf("%s %s has the same name as a method", kind, fn.name)
+		}
+		if accessors[kind+" "+fn.name] {
+			return fmt.Errorf("%s %s already defined", kind, fn.name)
+		}
+		accessors[kind+" "+fn.name] = true
+	}
+	return nil
+}
+
 func (v *VariableResolver) visitFunctionDef(f *FunctionDef) {
-	v.err = v.varInfo.addName(f.name)
-	if v.err != nil {
+	if f.class == nil {
+		v.resolveDecorators(f)
+		if v.err != nil {
+			return
+		}
+	}
+	if v.varInfo.vars[f.name] == hoisted {
+		v.varInfo.endVarDecl(f.name)
+	} else if !f.isAccessor() {
+		// getter and setter of a property share its name
+		v.err = v.varInfo.addName(f.name)
+		if v.err != nil {
+			return
+		}
+	}
+	v.resolveFunction(f)
+}
+
+// resolveDecorators resolves the decorators of a function in the enclosing
+// scope. They are evaluated before the function is defined, so they cannot
+// refer to the function itself.
+func (v *VariableResolver) resolveDecorators(f *FunctionDef) {
+	for _, decorator := range f.decorators {
+		decorator.accept(v)
+		if v.err != nil {
+			return
+		}
+	}
+}
+
+func (v *VariableResolver) visitAwaitExpr(awaitExpr *AwaitExpr) {
+	if !v.withinAsync {
+		v.err = fmt.Errorf("await is only allowed in async functions")
 		return
 	}
+	awaitExpr.value.accept(v)
+}
+
+func (v *VariableResolver) visitSpawnExpr(spawn *SpawnExpr) {
+	spawn.call.accept(v)
+}
+
+func (v *VariableResolver) visitFunctionExpr(f *FunctionExpr) {
+	v.resolveFunction(f.function)
+}
+
+func (v *VariableResolver) resolveFunction(f *FunctionDef) {
+	// loops outside the function body cannot be targeted by break or continue
+	loopLabels, withinGenerator, withinAsync := v.loopLabels, v.withinGenerator, v.withinAsync
+	v.loopLabels, v.withinGenerator, v.withinAsync = nil, f.isGenerator, f.isAsync
+	defer func() {
+		v.loopLabels, v.withinGenerator, v.withinAsync = loopLabels, withinGenerator, withinAsync
+	}()
+
 	v.varInfo = newVarInfo(v.varInfo)
 	v.varInfo.isParameterInfo = true
 	for _, param := range f.parameters {
-		v.err = v.varInfo.addName(param)
+		// default values can refer to the preceding parameters
+		if param.defaultValue != nil {
+			param.defaultValue.accept(v)
+			if v.err != nil {
+				return
+			}
+		}
+		v.err = v.varInfo.addName(param.name)
 		if v.err != nil {
 			return
 		}
@@ -245,15 +547,30 @@ func (v *VariableResolver) visitFunctionDef(f *FunctionDef) {
 
 func (v *VariableResolver) visitNumberExpr(*NumberExpr) {}
 
+func (v *VariableResolver) visitIntegerExpr(*IntegerExpr) {}
+
 func (v *VariableResolver) visitBooleanExpr(*BooleanExpr) {}
 
 func (v *VariableResolver) visitNilExpr() {}
 
 func (v *VariableResolver) visitStringExpr(*StringExpr) {}
 
+func (v *VariableResolver) visitInterpolationExpr(interpolation *InterpolationExpr) {
+	for _, part := range interpolation.parts {
+		part.accept(v)
+		if v.err != nil {
+			return
+		}
+	}
+}
+
 func (v *VariableResolver) visitIdentifierExpr(identifierExpr *IdentifierExpr) {
 	if identifierExpr.name == "this" && !v.withinMethod {
-		v.err = errors.New("'this' cannot be used outside of a method")
+		if v.withinStatic {
+			v.err = errors.New("'this' cannot be used in static members")
+		} else {
+			v.err = errors.New("'this' cannot be used outside of a method")
+		}
 		return
 	}
 	if identifierExpr.name == "super" {
@@ -288,10 +605,35 @@ func (v *VariableResolver) visitBinaryExpr(expr *BinaryExpr) {
 		return
 	}
 
-	_, isIdent = expr.Right.(*IdentifierExpr)
-	v.identifierIsPathSegment = isPath && isIdent
-	expr.Right.accept(v)
-	v.identifierIsPathSegment = false
+	if isPath {
+		v.resolveMember(expr.Right)
+	} else {
+		expr.Right.accept(v)
+	}
+}
+
+// resolveMember resolves the right hand side of a path expression. Member
+// names are no variables, so only the arguments of method calls are resolved.
+func (v *VariableResolver) resolveMember(expr Expr) {
+	switch member := expr.(type) {
+	case *IdentifierExpr:
+		return
+	case *Call:
+		v.resolveMember(member.callee)
+		if v.err == nil {
+			v.resolveArguments(member)
+		}
+	case *IndexExpr:
+		v.resolveMember(member.object)
+		if v.err == nil {
+			member.index.accept(v)
+		}
+	case *SliceExpr:
+		v.resolveMember(member.object)
+		v.resolveSliceBounds(member)
+	default:
+		expr.accept(v)
+	}
 }
 
 func (v *VariableResolver) visitAssignment(assignment *Assignment) {
@@ -299,9 +641,11 @@ func (v *VariableResolver) visitAssignment(assignment *Assignment) {
 	if v.err != nil {
 		return
 	}
-	identifier, ok := assignment.left.(*IdentifierExpr)
-	if ok {
-		assignment.defLevel, v.err = v.varInfo.getLevel(identifier.name)
+	switch left := assignment.left.(type) {
+	case *IdentifierExpr:
+		assignment.defLevel, v.err = v.varInfo.getLevel(left.name)
+	case *IndexExpr, *TupleExpr:
+		left.accept(v)
 	}
 }
 
@@ -310,7 +654,16 @@ func (v *VariableResolver) visitCall(call *Call) {
 	if v.err != nil {
 		return
 	}
-	for _, arg := range call.args {
+	v.resolveArguments(call)
+}
+
+func (v *VariableResolver) resolveArguments(call *Call) {
+	for i, arg := range call.args {
+		name := call.argNames[i]
+		if name != "" && slices.Contains(call.argNames[:i], name) {
+			v.err = fmt.Errorf("duplicate named argument %s", name)
+			return
+		}
 		arg.accept(v)
 		if v.err != nil {
 			return
@@ -318,6 +671,65 @@ func (v *VariableResolver) visitCall(call *Call) {
 	}
 }
 
+func (v *VariableResolver) visitSpreadExpr(spread *SpreadExpr) {
+	spread.inner.accept(v)
+}
+
+func (v *VariableResolver) visitListExpr(list *ListExpr) {
+	for _, element := range list.elements {
+		element.accept(v)
+		if v.err != nil {
+			return
+		}
+	}
+}
+
+func (v *VariableResolver) visitTupleExpr(tuple *TupleExpr) {
+	for _, element := range tuple.elements {
+		element.accept(v)
+		if v.err != nil {
+			return
+		}
+	}
+}
+
+func (v *VariableResolver) visitMapExpr(mapExpr *MapExpr) {
+	for i, key := range mapExpr.keys {
+		key.accept(v)
+		if v.err != nil {
+			return
+		}
+		mapExpr.values[i].accept(v)
+		if v.err != nil {
+			return
+		}
+	}
+}
+
+func (v *VariableResolver) visitIndexExpr(indexExpr *IndexExpr) {
+	indexExpr.object.accept(v)
+	if v.err != nil {
+		return
+	}
+	indexExpr.index.accept(v)
+}
+
+func (v *VariableResolver) visitSliceExpr(sliceExpr *SliceExpr) {
+	sliceExpr.object.accept(v)
+	v.resolveSliceBounds(sliceExpr)
+}
+
+func (v *VariableResolver) resolveSliceBounds(sliceExpr *SliceExpr) {
+	for _, bound := range []Expr{sliceExpr.start, sliceExpr.end} {
+		if v.err != nil {
+			return
+		}
+		if bound != nil {
+			bound.accept(v)
+		}
+	}
+}
+
 func (v *VariableResolver) inFunctionScope() bool {
 	ret := false
 	info := v.varInfo